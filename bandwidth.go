@@ -0,0 +1,270 @@
+package gohttpclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BandwidthLimit is a limit of the bytes transferred per second.
+type BandwidthLimit struct {
+	// Upload is the maximum number of bytes sent per second. Zero means no limit.
+	Upload int64
+
+	// Download is the maximum number of bytes received per second. Zero means no limit.
+	Download int64
+
+	// Burst is the maximum number of bytes transferred at once, after an idle time.
+	// Default is one second worth of bytes.
+	Burst int64
+}
+
+type bandwidthKey struct{}
+
+// WithBandwidthLimit returns a copy of ctx limiting the bandwidth of the request body upload
+// and response body download of requests sent with it, on top of the client limit if any.
+// Requests sent with the same returned context share the limit.
+func WithBandwidthLimit(ctx context.Context, limit BandwidthLimit) context.Context {
+	return context.WithValue(ctx, bandwidthKey{}, newBandwidthLimiter(limit))
+}
+
+func bandwidthLimiterFromContext(ctx context.Context) *bandwidthLimiter {
+	limiter, _ := ctx.Value(bandwidthKey{}).(*bandwidthLimiter)
+	return limiter
+}
+
+// bandwidthLimiter holds the buckets of a BandwidthLimit, nil when there is no limit.
+type bandwidthLimiter struct {
+	upload   *tokenBucket
+	download *tokenBucket
+}
+
+func newBandwidthLimiter(limit BandwidthLimit) *bandwidthLimiter {
+	if limit.Upload <= 0 && limit.Download <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{
+		upload:   newTokenBucket(limit.Upload, limit.Burst),
+		download: newTokenBucket(limit.Download, limit.Burst),
+	}
+}
+
+// limitUpload throttles the body of request with the client limit and the one
+// in its context, if any.
+func (c *client) limitUpload(request *http.Request) {
+	buckets := c.bandwidthBuckets(request.Context(), func(l *bandwidthLimiter) *tokenBucket { return l.upload })
+	if len(buckets) == 0 || request.Body == nil || request.Body == http.NoBody {
+		return
+	}
+	ctx := request.Context()
+	request.Body = newThrottledReader(ctx, request.Body, buckets)
+
+	if getBody := request.GetBody; getBody != nil {
+		request.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newThrottledReader(ctx, body, buckets), nil
+		}
+	}
+}
+
+// limitDownload throttles the body of response with the client limit and the one in ctx, if any.
+func (c *client) limitDownload(ctx context.Context, response *http.Response) {
+	buckets := c.bandwidthBuckets(ctx, func(l *bandwidthLimiter) *tokenBucket { return l.download })
+	if len(buckets) == 0 || response.Body == nil {
+		return
+	}
+	response.Body = newThrottledReader(ctx, response.Body, buckets)
+}
+
+func (c *client) bandwidthBuckets(ctx context.Context, direction func(*bandwidthLimiter) *tokenBucket) []*tokenBucket {
+	var buckets []*tokenBucket
+	for _, limiter := range []*bandwidthLimiter{c.bandwidthLimiter, bandwidthLimiterFromContext(ctx)} {
+		if limiter == nil {
+			continue
+		}
+		if bucket := direction(limiter); bucket != nil {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
+
+// tokenBucket allows rate bytes, or requests, per second, up to burst at once.
+// Transfers going over the limit wait until the bytes they took are refilled.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a bucket allowing rate bytes per second, nil if rate is not positive.
+func newTokenBucket(rate int64, burst int64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		now:    time.Now,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// newRequestRateLimiter returns a bucket allowing rate requests per second, nil if rate
+// is not positive.
+func newRequestRateLimiter(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		now:    time.Now,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// take takes n bytes from the bucket, returning how long to wait before transferring them.
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait takes n bytes from the bucket and waits until they're allowed, or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	delay := b.take(n)
+	if delay <= 0 {
+		return nil
+	}
+	return sleepContext(ctx, delay)
+}
+
+// maxTransfer returns the most bytes to transfer at once through buckets.
+func maxTransfer(buckets []*tokenBucket) int {
+	smallest := 0
+	for _, bucket := range buckets {
+		if smallest == 0 || int(bucket.burst) < smallest {
+			smallest = int(bucket.burst)
+		}
+	}
+	return smallest
+}
+
+// throttledReader limits the bytes read through it with buckets.
+type throttledReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	buckets []*tokenBucket
+	max     int
+}
+
+func newThrottledReader(ctx context.Context, body io.ReadCloser, buckets []*tokenBucket) *throttledReader {
+	return &throttledReader{
+		ReadCloser: body,
+		ctx:        ctx,
+		buckets:    buckets,
+		max:        maxTransfer(buckets),
+	}
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > r.max {
+		p = p[:r.max]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		for _, bucket := range r.buckets {
+			if waitErr := bucket.wait(r.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+	return n, err
+}
+
+// limitedDialContext returns a dial function making connections limited by limiter.
+// Every connection shares the limit, which covers all the bytes transferred,
+// like headers and TLS handshakes.
+func limitedDialContext(dial func(ctx context.Context, network string, address string) (net.Conn, error), limiter *bandwidthLimiter) func(ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &throttledConn{Conn: conn, limiter: limiter}, nil
+	}
+}
+
+// throttledConn limits the bytes read from and written to a connection.
+// Waits don't observe the connection deadlines.
+type throttledConn struct {
+	net.Conn
+	limiter *bandwidthLimiter
+}
+
+func (c *throttledConn) Read(p []byte) (int, error) {
+	bucket := c.limiter.download
+	if bucket == nil {
+		return c.Conn.Read(p)
+	}
+	if len(p) > int(bucket.burst) {
+		p = p[:int(bucket.burst)]
+	}
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		time.Sleep(bucket.take(n))
+	}
+	return n, err
+}
+
+func (c *throttledConn) Write(p []byte) (int, error) {
+	bucket := c.limiter.upload
+	if bucket == nil {
+		return c.Conn.Write(p)
+	}
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		if len(chunk) > int(bucket.burst) {
+			chunk = chunk[:int(bucket.burst)]
+		}
+		time.Sleep(bucket.take(len(chunk)))
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,125 @@
+package gohttpclient
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+
+	// Initialization
+	now := time.Now()
+	bucket := newTokenBucket(1000, 500)
+	bucket.now = func() time.Time { return now }
+	bucket.last = now
+
+	// Execution
+	burst := bucket.take(500)
+	over := bucket.take(250)
+	now = now.Add(time.Second)
+	refilled := bucket.take(500)
+
+	// Validation
+	if burst != 0 {
+		t.Errorf("Burst should not wait, got %v", burst)
+	}
+	if over != 250*time.Millisecond {
+		t.Errorf("Invalid wait over the burst %v", over)
+	}
+	// 750 refilled, capped to the burst
+	if refilled != 0 {
+		t.Errorf("Refilled bucket should not wait, got %v", refilled)
+	}
+}
+
+func TestRequestDownloadBandwidthLimit(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("a", 3000))
+	}))
+	defer server.Close()
+
+	ctx := WithBandwidthLimit(context.Background(), BandwidthLimit{Download: 10000, Burst: 1000})
+
+	// Execution
+	start := time.Now()
+	response, err := NewBuilder().Build().Do(ctx, http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	elapsed := time.Since(start)
+
+	// Validation
+	if err != nil || len(data) != 3000 {
+		t.Fatalf("Invalid body of %d bytes, error %v", len(data), err)
+	}
+	// 2000 bytes over the burst at 10000 per second
+	if elapsed < 150*time.Millisecond {
+		t.Errorf("Download should be throttled, took %v", elapsed)
+	}
+}
+
+func TestClientUploadBandwidthLimit(t *testing.T) {
+
+	// Initialization
+	received := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		received <- len(data)
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetBandwidthLimit(BandwidthLimit{Upload: 10000, Burst: 1000}).Build()
+
+	// Execution
+	start := time.Now()
+	response, err := c.POST(server.URL, nil, strings.Repeat("a", 2998))
+	elapsed := time.Since(start)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	response.Body.Close()
+	if size := <-received; size != 3000 {
+		t.Errorf("Invalid body of %d bytes received", size)
+	}
+	if elapsed < 150*time.Millisecond {
+		t.Errorf("Upload should be throttled, took %v", elapsed)
+	}
+}
+
+func TestConnectionBandwidthLimit(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("a", 6000))
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetConnectionBandwidthLimit(BandwidthLimit{Download: 20000, Burst: 2000}).Build()
+
+	// Execution
+	start := time.Now()
+	response, err := c.GET(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	elapsed := time.Since(start)
+
+	// Validation
+	if elapsed < 150*time.Millisecond {
+		t.Errorf("Connection should be throttled, took %v", elapsed)
+	}
+}
@@ -0,0 +1,163 @@
+package gohttpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const defaultBatchConcurrency int = 10
+
+// ErrBatchAborted is the error of the requests of a fail-fast batch not completed
+// because another one failed.
+var ErrBatchAborted = errors.New("batch aborted after a request failed")
+
+// BatchRequest is a request of a batch.
+type BatchRequest struct {
+	Method  string
+	URL     string
+	Headers http.Header
+	Body    interface{}
+}
+
+// BatchResult is the result of a request of a batch.
+type BatchResult struct {
+	// Response, with its body already read into Body and closed. Nil if Err is set
+	// before a response was received.
+	Response *http.Response
+
+	Body []byte
+
+	Err error
+}
+
+// BatchOptions configures how a batch is executed.
+type BatchOptions struct {
+	// Concurrency is the maximum number of requests sent at the same time.
+	// Default is 10.
+	Concurrency int
+
+	// FailFast stops the batch once a request fails, cancelling the ones in flight.
+	// Default is executing every request, whatever the result of the others.
+	FailFast bool
+}
+
+// BatchError is the failure of a request that stopped a fail-fast batch.
+type BatchError struct {
+	// Index of the request in the batch.
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("request %d of batch failed. %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ExecuteBatch sends requests with client, at most options.Concurrency at the same time,
+// and returns their results in the same order. Requests go through client.Do, so the
+// client settings, like bandwidth and request rate limits, apply to them as a whole,
+// together with the other requests of the client.
+// A request fails when it gets an error, not an error status.
+//
+// It returns a BatchError if a fail-fast batch stopped, or the ctx error if ctx was
+// done before every request completed. Otherwise, the error of each request is in
+// its result.
+func ExecuteBatch(ctx context.Context, client Doer, requests []BatchRequest, options BatchOptions) ([]BatchResult, error) {
+	results := make([]BatchResult, len(requests))
+	if len(requests) == 0 {
+		return results, nil
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if concurrency > len(requests) {
+		concurrency = len(requests)
+	}
+
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var failOnce sync.Once
+	var batchErr *BatchError
+
+	indexes := make(chan int)
+	var workers sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range indexes {
+				result := executeBatchRequest(batchCtx, client, requests[i])
+				if result.Err != nil && batchCtx.Err() != nil && ctx.Err() == nil {
+					// Cancelled by a failure of another request
+					result.Err = ErrBatchAborted
+				}
+				results[i] = result
+
+				if result.Err != nil && result.Err != ErrBatchAborted && options.FailFast {
+					failOnce.Do(func() {
+						batchErr = &BatchError{Index: i, Err: result.Err}
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	next := 0
+	for ; next < len(requests); next++ {
+		select {
+		case indexes <- next:
+			continue
+		case <-batchCtx.Done():
+		}
+		break
+	}
+	close(indexes)
+	workers.Wait()
+
+	for i := next; i < len(requests); i++ {
+		results[i].Err = ErrBatchAborted
+		if ctx.Err() != nil {
+			results[i].Err = ctx.Err()
+		}
+	}
+
+	if batchErr != nil {
+		return results, batchErr
+	}
+	if ctx.Err() != nil {
+		for _, result := range results {
+			if result.Err != nil {
+				return results, ctx.Err()
+			}
+		}
+	}
+	return results, nil
+}
+
+func executeBatchRequest(ctx context.Context, client Doer, request BatchRequest) BatchResult {
+	response, err := client.Do(ctx, request.Method, request.URL, request.Headers, request.Body)
+	if err != nil {
+		if response != nil {
+			response.Body.Close()
+		}
+		return BatchResult{Response: response, Err: err}
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return BatchResult{Response: response, Err: fmt.Errorf("unable to read response body. %v", err)}
+	}
+	return BatchResult{Response: response, Body: body}
+}
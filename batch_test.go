@@ -0,0 +1,154 @@
+package gohttpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecuteBatchOrderAndConcurrency(t *testing.T) {
+
+	// Initialization
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer server.Close()
+
+	var requests []BatchRequest
+	for i := 0; i < 20; i++ {
+		requests = append(requests, BatchRequest{Method: http.MethodGet, URL: fmt.Sprintf("%s/%d", server.URL, i)})
+	}
+
+	// Execution
+	results, err := ExecuteBatch(context.Background(), NewBuilder().Build(), requests, BatchOptions{Concurrency: 3})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing batch: %v", err)
+	}
+	for i, result := range results {
+		if result.Err != nil || string(result.Body) != fmt.Sprintf("/%d", i) {
+			t.Errorf("Invalid result %d: %q, error %v", i, result.Body, result.Err)
+		}
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 3 {
+		t.Errorf("At most 3 requests should be in flight, got %d", max)
+	}
+}
+
+func TestExecuteBatchCollectAll(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	requests := []BatchRequest{
+		{Method: http.MethodGet, URL: "://invalid"},
+		{Method: http.MethodGet, URL: server.URL},
+	}
+
+	// Execution
+	results, err := ExecuteBatch(context.Background(), NewBuilder().Build(), requests, BatchOptions{})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Collect-all batch should not fail, got %v", err)
+	}
+	if results[0].Err == nil {
+		t.Error("Invalid request should fail")
+	}
+	if results[1].Err != nil || results[1].Response.StatusCode != http.StatusNotFound {
+		t.Errorf("Error status should be a result, got %+v", results[1])
+	}
+}
+
+func TestExecuteBatchFailFast(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer server.Close()
+
+	requests := []BatchRequest{
+		{Method: http.MethodGet, URL: "://invalid"},
+		{Method: http.MethodGet, URL: server.URL},
+		{Method: http.MethodGet, URL: server.URL},
+	}
+
+	// Execution
+	results, err := ExecuteBatch(context.Background(), NewBuilder().Build(), requests, BatchOptions{Concurrency: 1, FailFast: true})
+
+	// Validation
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 0 {
+		t.Fatalf("Batch error of the first request expected, got %v", err)
+	}
+	if results[1].Err != ErrBatchAborted || results[2].Err != ErrBatchAborted {
+		t.Errorf("Remaining requests should be aborted, got %v and %v", results[1].Err, results[2].Err)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("Server got %d requests", hits)
+	}
+}
+
+func TestExecuteBatchRequestRateLimit(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer server.Close()
+
+	requests := make([]BatchRequest, 3)
+	for i := range requests {
+		requests[i] = BatchRequest{Method: http.MethodGet, URL: server.URL}
+	}
+	c := NewBuilder().SetRequestRateLimit(20, 1).Build()
+
+	// Execution
+	start := time.Now()
+	var wg sync.WaitGroup
+	for b := 0; b < 2; b++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ExecuteBatch(context.Background(), c, requests, BatchOptions{Concurrency: 3}); err != nil {
+				t.Errorf("Error executing batch: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Validation
+	// Both batches share the client limit: the first request is sent at once, and the
+	// others every 50 milliseconds
+	if elapsed < 240*time.Millisecond {
+		t.Errorf("6 requests at 20 per second should take 250ms, took %v", elapsed)
+	}
+	if atomic.LoadInt32(&hits) != 6 {
+		t.Errorf("Server got %d requests", hits)
+	}
+}
@@ -0,0 +1,521 @@
+package gohttpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStatusHeader is set on every response to a cacheable request when a cache is set,
+// telling how it was served: CacheHit, CacheMiss, CacheRevalidated or CacheStale.
+const CacheStatusHeader = "X-Cache"
+
+const (
+	// CacheHit means the response was served from the cache without contacting the server.
+	CacheHit = "HIT"
+	// CacheMiss means the response comes from the server.
+	CacheMiss = "MISS"
+	// CacheRevalidated means the server confirmed the cached response is still valid.
+	CacheRevalidated = "REVALIDATED"
+	// CacheStale means a stale cached response was served, because of
+	// stale-while-revalidate, stale-if-error or max-stale.
+	CacheStale = "STALE"
+)
+
+const (
+	// Responses with bigger bodies are not cached, to bound memory usage.
+	maxCacheableBodySize = 10 << 20
+
+	maxHeuristicFreshness = 24 * time.Hour
+)
+
+// Status codes cacheable by default, RFC 9110 section 15.1.
+var heuristicallyCacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// responseCache is an RFC 9111 private or shared cache of GET responses.
+type responseCache struct {
+	storage CacheStorage
+	shared  bool
+	now     func() time.Time
+
+	mutex        sync.Mutex
+	revalidating map[string]bool
+}
+
+type cacheEntry struct {
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	RequestTime  time.Time   `json:"request_time"`
+	ResponseTime time.Time   `json:"response_time"`
+	// Vary keeps the request header values the response varies on.
+	Vary map[string]string `json:"vary"`
+}
+
+func newResponseCache(storage CacheStorage, shared bool) *responseCache {
+	return &responseCache{
+		storage:      storage,
+		shared:       shared,
+		now:          time.Now,
+		revalidating: make(map[string]bool),
+	}
+}
+
+// sendCached serves GET requests from the cache when possible, revalidating stale
+// responses, and stores the cacheable responses received.
+// Successful unsafe requests invalidate the cached response of their URL.
+// Requests with their own conditional headers bypass the cache.
+func (c *client) sendCached(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+	cache := c.responseCache
+	key := cache.key(url, headers)
+
+	if method != http.MethodGet || hasConditionalHeaders(headers) {
+		response, err := c.roundTrip(ctx, method, url, headers, body)
+		if err == nil && !isSafeMethod(method) && response.StatusCode < http.StatusBadRequest {
+			cache.storage.Delete(key)
+		}
+		return response, err
+	}
+
+	requestCC := parseCacheControl(headers)
+	entry := cache.load(key, headers)
+	if entry == nil {
+		return c.fetchCacheable(ctx, url, headers, nil)
+	}
+
+	responseCC := parseCacheControl(entry.Header)
+	age := entry.age(cache.now())
+	lifetime := cache.freshnessLifetime(entry, responseCC)
+
+	_, requestNoCache := requestCC["no-cache"]
+	_, responseNoCache := responseCC["no-cache"]
+	revalidate := requestNoCache || responseNoCache
+	if maxAge, ok := cacheControlSeconds(requestCC, "max-age"); ok && age > maxAge {
+		revalidate = true
+	}
+	if minFresh, ok := cacheControlSeconds(requestCC, "min-fresh"); ok && lifetime-age < minFresh {
+		revalidate = true
+	}
+
+	if !revalidate {
+		if age < lifetime {
+			return c.cachedResponse(ctx, url, headers, entry, CacheHit), nil
+		}
+		staleness := age - lifetime
+		if cache.canServeStale(responseCC) {
+			if maxStale, ok := requestCC["max-stale"]; ok {
+				if limit, err := strconv.Atoi(maxStale); err != nil || time.Duration(limit)*time.Second >= staleness {
+					return c.cachedResponse(ctx, url, headers, entry, CacheStale), nil
+				}
+			}
+			if window, ok := cacheControlSeconds(responseCC, "stale-while-revalidate"); ok && staleness <= window {
+				response := c.cachedResponse(ctx, url, headers, entry, CacheStale)
+				c.revalidateInBackground(key, url, headers, entry)
+				return response, nil
+			}
+		}
+	}
+
+	return c.fetchCacheable(ctx, url, headers, entry)
+}
+
+// fetchCacheable gets the response from the server, revalidating the stored entry if any.
+// When revalidation fails, the stale entry may be served according to stale-if-error.
+func (c *client) fetchCacheable(ctx context.Context, url string, headers http.Header, stored *cacheEntry) (*http.Response, error) {
+	cache := c.responseCache
+	key := cache.key(url, headers)
+
+	requestHeaders := headers
+	if stored != nil {
+		requestHeaders = headers.Clone()
+		if etag := stored.Header.Get("ETag"); etag != "" {
+			requestHeaders.Set("If-None-Match", etag)
+		}
+		if lastModified := stored.Header.Get("Last-Modified"); lastModified != "" {
+			requestHeaders.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	requestTime := cache.now()
+	response, err := c.roundTrip(ctx, http.MethodGet, url, requestHeaders, nil)
+	responseTime := cache.now()
+
+	if stored != nil && (err != nil || response.StatusCode >= http.StatusInternalServerError) {
+		if cache.canServeStaleOnError(stored, parseCacheControl(headers)) {
+			if response != nil {
+				discardBody(response)
+			}
+			return c.cachedResponse(ctx, url, headers, stored, CacheStale), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if response.Header == nil {
+		response.Header = make(http.Header)
+	}
+
+	if stored != nil && response.StatusCode == http.StatusNotModified {
+		discardBody(response)
+		stored.update(response.Header, requestTime, responseTime)
+		cache.store(key, stored)
+		return c.cachedResponse(ctx, url, headers, stored, CacheRevalidated), nil
+	}
+
+	if !cache.storable(headers, response) {
+		if stored != nil {
+			cache.storage.Delete(key)
+		}
+		response.Header.Set(CacheStatusHeader, CacheMiss)
+		return response, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxCacheableBodySize+1))
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCacheableBodySize {
+		response.Body = &prefixedReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(body), response.Body),
+			Closer: response.Body,
+		}
+		response.Header.Set(CacheStatusHeader, CacheMiss)
+		return response, nil
+	}
+	response.Body.Close()
+
+	cache.store(key, &cacheEntry{
+		StatusCode:   response.StatusCode,
+		Header:       response.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+		Vary:         varyValues(response.Header, headers),
+	})
+
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	response.Header.Set(CacheStatusHeader, CacheMiss)
+	return response, nil
+}
+
+// revalidateInBackground revalidates the entry without blocking the caller,
+// once at a time per key. Close waits for it, and no revalidation starts afterwards.
+func (c *client) revalidateInBackground(key string, url string, headers http.Header, entry *cacheEntry) {
+	cache := c.responseCache
+
+	cache.mutex.Lock()
+	if cache.revalidating[key] {
+		cache.mutex.Unlock()
+		return
+	}
+	cache.revalidating[key] = true
+	cache.mutex.Unlock()
+
+	done := func() {
+		cache.mutex.Lock()
+		delete(cache.revalidating, key)
+		cache.mutex.Unlock()
+	}
+	started := c.runInBackground(func() {
+		defer done()
+
+		// Closing the client aborts the revalidation
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-c.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		response, err := c.fetchCacheable(ctx, url, headers, entry)
+		if err == nil {
+			discardBody(response)
+		}
+	})
+	if !started {
+		done()
+	}
+}
+
+func (c *client) cachedResponse(ctx context.Context, url string, headers http.Header, entry *cacheEntry, status string) *http.Response {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(entry.age(c.responseCache.now()).Seconds())))
+	header.Set(CacheStatusHeader, status)
+
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if request != nil {
+		request.Header = headers.Clone()
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       request,
+	}
+}
+
+// load returns the stored entry for the key if it matches the request Vary headers.
+func (r *responseCache) load(key string, headers http.Header) *cacheEntry {
+	value, ok := r.storage.Get(key)
+	if !ok {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(value, &entry); err != nil {
+		r.storage.Delete(key)
+		return nil
+	}
+	for name, value := range entry.Vary {
+		if strings.Join(headers.Values(name), ", ") != value {
+			return nil
+		}
+	}
+	return &entry
+}
+
+func (r *responseCache) store(key string, entry *cacheEntry) {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	r.storage.Set(key, value)
+}
+
+// storable tells whether the response can be stored, RFC 9111 section 3.
+func (r *responseCache) storable(requestHeaders http.Header, response *http.Response) bool {
+	requestCC := parseCacheControl(requestHeaders)
+	responseCC := parseCacheControl(response.Header)
+
+	if _, ok := requestCC["no-store"]; ok {
+		return false
+	}
+	if _, ok := responseCC["no-store"]; ok {
+		return false
+	}
+	if response.StatusCode == http.StatusPartialContent || response.StatusCode < http.StatusOK {
+		return false
+	}
+	for _, vary := range response.Header.Values("Vary") {
+		if strings.Contains(vary, "*") {
+			return false
+		}
+	}
+
+	_, private := responseCC["private"]
+	_, public := responseCC["public"]
+	_, sMaxAge := responseCC["s-maxage"]
+	if r.shared {
+		if private {
+			return false
+		}
+		_, mustRevalidate := responseCC["must-revalidate"]
+		if requestHeaders.Get("Authorization") != "" && !mustRevalidate && !public && !sMaxAge {
+			return false
+		}
+	}
+
+	_, maxAge := responseCC["max-age"]
+	explicit := maxAge || public || response.Header.Get("Expires") != "" ||
+		(r.shared && sMaxAge) || (!r.shared && private)
+	return explicit || heuristicallyCacheableStatus[response.StatusCode]
+}
+
+// freshnessLifetime is computed as defined by RFC 9111 section 4.2.1.
+func (r *responseCache) freshnessLifetime(entry *cacheEntry, responseCC map[string]string) time.Duration {
+	if r.shared {
+		if sMaxAge, ok := cacheControlSeconds(responseCC, "s-maxage"); ok {
+			return sMaxAge
+		}
+	}
+	if maxAge, ok := cacheControlSeconds(responseCC, "max-age"); ok {
+		return maxAge
+	}
+
+	date := entry.date()
+	if expires := entry.Header.Get("Expires"); expires != "" {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil || expiresTime.Before(date) {
+			return 0
+		}
+		return expiresTime.Sub(date)
+	}
+
+	if lastModified, err := http.ParseTime(entry.Header.Get("Last-Modified")); err == nil && heuristicallyCacheableStatus[entry.StatusCode] {
+		heuristic := date.Sub(lastModified) / 10
+		if heuristic > maxHeuristicFreshness {
+			heuristic = maxHeuristicFreshness
+		}
+		if heuristic > 0 {
+			return heuristic
+		}
+	}
+	return 0
+}
+
+func (r *responseCache) canServeStale(responseCC map[string]string) bool {
+	if _, ok := responseCC["must-revalidate"]; ok {
+		return false
+	}
+	if _, ok := responseCC["proxy-revalidate"]; ok && r.shared {
+		return false
+	}
+	if _, ok := responseCC["no-cache"]; ok {
+		return false
+	}
+	return true
+}
+
+// canServeStaleOnError applies stale-if-error, RFC 5861, from the response or the request.
+func (r *responseCache) canServeStaleOnError(entry *cacheEntry, requestCC map[string]string) bool {
+	responseCC := parseCacheControl(entry.Header)
+	if !r.canServeStale(responseCC) {
+		return false
+	}
+	staleness := entry.age(r.now()) - r.freshnessLifetime(entry, responseCC)
+
+	for _, cc := range []map[string]string{requestCC, responseCC} {
+		if window, ok := cacheControlSeconds(cc, "stale-if-error"); ok && staleness <= window {
+			return true
+		}
+	}
+	return false
+}
+
+// age is the current age of the entry, RFC 9111 section 4.2.3.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	ageValue, _ := strconv.Atoi(e.Header.Get("Age"))
+	correctedAgeValue := time.Duration(ageValue)*time.Second + e.ResponseTime.Sub(e.RequestTime)
+
+	correctedInitialAge := apparentAge
+	if correctedAgeValue > correctedInitialAge {
+		correctedInitialAge = correctedAgeValue
+	}
+	return correctedInitialAge + now.Sub(e.ResponseTime)
+}
+
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+// update refreshes the entry with the headers of a 304 Not Modified response.
+func (e *cacheEntry) update(header http.Header, requestTime, responseTime time.Time) {
+	for key, values := range header {
+		if key == "Content-Length" {
+			continue
+		}
+		e.Header[key] = values
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+// parseCacheControl returns the Cache-Control directives, lowercased, with their unquoted values.
+func parseCacheControl(headers http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range headers.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, directiveValue := directive, ""
+			if eq := strings.IndexByte(directive, '='); eq >= 0 {
+				name = directive[:eq]
+				directiveValue = strings.Trim(strings.TrimSpace(directive[eq+1:]), `"`)
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = directiveValue
+		}
+	}
+	return directives
+}
+
+func cacheControlSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func varyValues(responseHeader http.Header, requestHeaders http.Header) map[string]string {
+	values := make(map[string]string)
+	for _, vary := range responseHeader.Values("Vary") {
+		for _, name := range strings.Split(vary, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" {
+				values[name] = strings.Join(requestHeaders.Values(name), ", ")
+			}
+		}
+	}
+	return values
+}
+
+func hasConditionalHeaders(headers http.Header) bool {
+	return headers.Get("If-None-Match") != "" || headers.Get("If-Modified-Since") != "" ||
+		headers.Get("If-Match") != "" || headers.Get("If-Unmodified-Since") != "" || headers.Get("If-Range") != ""
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// key identifies the stored response of a URL. A private cache keeps a response per
+// credentials, so it's never served to a request made on behalf of someone else.
+func (r *responseCache) key(url string, headers http.Header) string {
+	var builder strings.Builder
+	builder.WriteString(http.MethodGet)
+	builder.WriteString(" ")
+	builder.WriteString(url)
+	if !r.shared {
+		for _, header := range credentialHeaders {
+			if values := headers.Values(header); len(values) > 0 {
+				builder.WriteString("\n")
+				builder.WriteString(header)
+				builder.WriteString(": ")
+				builder.WriteString(strings.Join(values, ", "))
+			}
+		}
+	}
+	return builder.String()
+}
+
+func discardBody(response *http.Response) {
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+}
@@ -0,0 +1,162 @@
+package gohttpclient
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStorage stores serialized cached responses.
+// Implementations must be safe for concurrent use by multiple goroutines.
+type CacheStorage interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// MemoryCache is an in-memory CacheStorage evicting the least recently used
+// entries once its size bound is reached.
+type MemoryCache struct {
+	mutex sync.Mutex
+
+	maxBytes  int64
+	usedBytes int64
+
+	entries  map[string]*list.Element
+	lruOrder *list.List // front is the most recently used
+}
+
+type memoryCacheEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCache returns an empty MemoryCache holding up to maxBytes of keys and values.
+// Zero or negative means no limit.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lruOrder: list.New(),
+	}
+}
+
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.lruOrder.MoveToFront(element)
+	return element.Value.(*memoryCacheEntry).value, true
+}
+
+// Set stores the value, evicting the least recently used entries if needed.
+// Values bigger than the whole cache are not stored.
+func (m *MemoryCache) Set(key string, value []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	size := int64(len(key) + len(value))
+	if m.maxBytes > 0 && size > m.maxBytes {
+		m.remove(key)
+		return
+	}
+
+	if element, ok := m.entries[key]; ok {
+		entry := element.Value.(*memoryCacheEntry)
+		m.usedBytes += int64(len(value) - len(entry.value))
+		entry.value = value
+		m.lruOrder.MoveToFront(element)
+	} else {
+		m.entries[key] = m.lruOrder.PushFront(&memoryCacheEntry{key: key, value: value})
+		m.usedBytes += size
+	}
+
+	for m.maxBytes > 0 && m.usedBytes > m.maxBytes {
+		oldest := m.lruOrder.Back()
+		m.remove(oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.remove(key)
+}
+
+// Size returns the bytes used by keys and values.
+func (m *MemoryCache) Size() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.usedBytes
+}
+
+func (m *MemoryCache) remove(key string) {
+	element, ok := m.entries[key]
+	if !ok {
+		return
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	m.usedBytes -= int64(len(entry.key) + len(entry.value))
+	m.lruOrder.Remove(element)
+	delete(m.entries, key)
+}
+
+// DiskCache is a CacheStorage keeping every entry in its own file inside a directory,
+// so cached responses survive restarts.
+type DiskCache struct {
+	directory string
+}
+
+// NewDiskCache returns a DiskCache storing entries in directory, creating it if needed.
+func NewDiskCache(directory string) (*DiskCache, error) {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create cache directory. %v", err)
+	}
+	return &DiskCache{directory: directory}, nil
+}
+
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	value, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Set writes the entry to a temporary file first, and renames it,
+// so readers never see partially written entries.
+func (d *DiskCache) Set(key string, value []byte) {
+	file, err := ioutil.TempFile(d.directory, "tmp-")
+	if err != nil {
+		return
+	}
+	_, writeErr := file.Write(value)
+	closeErr := file.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(file.Name())
+		return
+	}
+	if err := os.Rename(file.Name(), d.path(key)); err != nil {
+		os.Remove(file.Name())
+	}
+}
+
+func (d *DiskCache) Delete(key string) {
+	os.Remove(d.path(key))
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.directory, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,434 @@
+package gohttpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func newCachedTestClient(shared bool) (*client, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	c := NewBuilder().SetCache(NewMemoryCache(1<<20), shared).Build().(*client)
+	c.responseCache.now = clock.Now
+	return c, clock
+}
+
+func getCached(t *testing.T, c Client, url string, headers http.Header) (*http.Response, string) {
+	t.Helper()
+	resp, err := c.GET(url, headers)
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+func TestCacheMaxAge(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("response " + strconv.Itoa(int(count))))
+	}))
+	defer server.Close()
+
+	c, clock := newCachedTestClient(false)
+
+	// Execution
+	first, firstBody := getCached(t, c, server.URL, nil)
+	second, secondBody := getCached(t, c, server.URL, nil)
+	clock.Advance(61 * time.Second)
+	third, thirdBody := getCached(t, c, server.URL, nil)
+
+	// Validation
+	if first.Header.Get(CacheStatusHeader) != CacheMiss || second.Header.Get(CacheStatusHeader) != CacheHit {
+		t.Errorf("Invalid cache status %s, %s", first.Header.Get(CacheStatusHeader), second.Header.Get(CacheStatusHeader))
+	}
+	if firstBody != "response 1" || secondBody != "response 1" {
+		t.Errorf("Invalid bodies %q, %q", firstBody, secondBody)
+	}
+	if third.Header.Get(CacheStatusHeader) != CacheMiss || thirdBody != "response 2" {
+		t.Errorf("Expired response was served: %s %q", third.Header.Get(CacheStatusHeader), thirdBody)
+	}
+	if atomic.LoadInt32(&hits) != 2 {
+		t.Errorf("Server got %d requests", hits)
+	}
+}
+
+func TestCacheRevalidation(t *testing.T) {
+
+	// Initialization
+	var hits, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	c, _ := newCachedTestClient(false)
+
+	// Execution
+	getCached(t, c, server.URL, nil)
+	resp, body := getCached(t, c, server.URL, nil)
+
+	// Validation
+	if resp.Header.Get(CacheStatusHeader) != CacheRevalidated || resp.StatusCode != http.StatusOK {
+		t.Errorf("Invalid cache status %s, code %d", resp.Header.Get(CacheStatusHeader), resp.StatusCode)
+	}
+	if body != "payload" {
+		t.Errorf("Invalid body %q", body)
+	}
+	if atomic.LoadInt32(&hits) != 2 || atomic.LoadInt32(&notModified) != 1 {
+		t.Errorf("Server got %d requests, %d not modified", hits, notModified)
+	}
+}
+
+func TestCacheNotStored(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		}
+	}))
+	defer server.Close()
+
+	sharedClient, _ := newCachedTestClient(true)
+	privateClient, _ := newCachedTestClient(false)
+
+	english := make(http.Header)
+	english.Set("Accept-Language", "en")
+	spanish := make(http.Header)
+	spanish.Set("Accept-Language", "es")
+
+	// Execution
+	getCached(t, privateClient, server.URL+"/no-store", nil)
+	getCached(t, privateClient, server.URL+"/no-store", nil)
+
+	getCached(t, sharedClient, server.URL+"/private", nil)
+	getCached(t, sharedClient, server.URL+"/private", nil)
+
+	getCached(t, privateClient, server.URL+"/private", nil)
+	privateResp, _ := getCached(t, privateClient, server.URL+"/private", nil)
+
+	getCached(t, privateClient, server.URL+"/vary", english)
+	spanishResp, _ := getCached(t, privateClient, server.URL+"/vary", spanish)
+	repeatedResp, _ := getCached(t, privateClient, server.URL+"/vary", spanish)
+
+	// Validation
+	if privateResp.Header.Get(CacheStatusHeader) != CacheHit {
+		t.Error("Private responses should be stored by a private cache")
+	}
+	if spanishResp.Header.Get(CacheStatusHeader) != CacheMiss {
+		t.Error("Response varying on a different header value should not be served")
+	}
+	if repeatedResp.Header.Get(CacheStatusHeader) != CacheHit {
+		t.Error("Response matching the varying header should be served")
+	}
+	// 2 no-store, 2 private on the shared cache, 1 private on the private cache, 2 vary
+	if atomic.LoadInt32(&hits) != 7 {
+		t.Errorf("Server got %d requests", hits)
+	}
+}
+
+func TestCacheCredentials(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("data for " + r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	c, _ := newCachedTestClient(false)
+
+	alice := make(http.Header)
+	alice.Set("Authorization", "Bearer alice")
+	bob := make(http.Header)
+	bob.Set("Authorization", "Bearer bob")
+
+	// Execution
+	getCached(t, c, server.URL, alice)
+	bobResp, bobBody := getCached(t, c, server.URL, bob)
+	aliceResp, aliceBody := getCached(t, c, server.URL, alice)
+
+	// Validation
+	if bobResp.Header.Get(CacheStatusHeader) != CacheMiss || bobBody != "data for Bearer bob" {
+		t.Errorf("Response of another user was served: %s %q", bobResp.Header.Get(CacheStatusHeader), bobBody)
+	}
+	if aliceResp.Header.Get(CacheStatusHeader) != CacheHit || aliceBody != "data for Bearer alice" {
+		t.Errorf("Response of the same user should be served: %s %q", aliceResp.Header.Get(CacheStatusHeader), aliceBody)
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+
+	// Initialization
+	var failing int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=10, stale-if-error=60")
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	c, clock := newCachedTestClient(false)
+	getCached(t, c, server.URL, nil)
+	atomic.StoreInt32(&failing, 1)
+
+	// Execution
+	clock.Advance(30 * time.Second)
+	stale, staleBody := getCached(t, c, server.URL, nil)
+	clock.Advance(60 * time.Second)
+	failed, _ := getCached(t, c, server.URL, nil)
+
+	// Validation
+	if stale.Header.Get(CacheStatusHeader) != CacheStale || stale.StatusCode != http.StatusOK || staleBody != "payload" {
+		t.Errorf("Stale response was not served: %s %d %q", stale.Header.Get(CacheStatusHeader), stale.StatusCode, staleBody)
+	}
+	if failed.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Stale response served outside the stale-if-error window, status %d", failed.StatusCode)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	revalidated := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := atomic.AddInt32(&hits, 1)
+		// The client clock is faked, so the real Date would make responses look older
+		w.Header()["Date"] = nil
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		w.Write([]byte("response " + strconv.Itoa(int(count))))
+		if count == 2 {
+			revalidated <- struct{}{}
+		}
+	}))
+	defer server.Close()
+
+	c, clock := newCachedTestClient(false)
+	getCached(t, c, server.URL, nil)
+
+	// Execution
+	clock.Advance(30 * time.Second)
+	stale, staleBody := getCached(t, c, server.URL, nil)
+
+	select {
+	case <-revalidated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Background revalidation didn't happen")
+	}
+	// Wait for the revalidated response to be stored
+	deadline := time.Now().Add(5 * time.Second)
+	var fresh *http.Response
+	var freshBody string
+	for time.Now().Before(deadline) {
+		fresh, freshBody = getCached(t, c, server.URL, nil)
+		if freshBody == "response 2" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Validation
+	if stale.Header.Get(CacheStatusHeader) != CacheStale || staleBody != "response 1" {
+		t.Errorf("Stale response was not served: %s %q", stale.Header.Get(CacheStatusHeader), staleBody)
+	}
+	if fresh.Header.Get(CacheStatusHeader) != CacheHit || freshBody != "response 2" {
+		t.Errorf("Revalidated response was not stored: %s %q", fresh.Header.Get(CacheStatusHeader), freshBody)
+	}
+}
+
+func TestCacheCloseAbortsRevalidation(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	revalidating := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Date"] = nil
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		if atomic.AddInt32(&hits, 1) == 2 {
+			close(revalidating)
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("response"))
+	}))
+	defer server.Close()
+
+	c, clock := newCachedTestClient(false)
+	getCached(t, c, server.URL, nil)
+	clock.Advance(30 * time.Second)
+	getCached(t, c, server.URL, nil)
+	<-revalidating
+
+	// Execution
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+
+	// Validation
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close should abort the background revalidation")
+	}
+}
+
+func TestCacheNoRevalidationAfterClose(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header()["Date"] = nil
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		w.Write([]byte("response"))
+	}))
+	defer server.Close()
+
+	c, clock := newCachedTestClient(false)
+	getCached(t, c, server.URL, nil)
+	clock.Advance(30 * time.Second)
+	c.Close()
+
+	// Execution
+	stale, _ := getCached(t, c, server.URL, nil)
+	time.Sleep(50 * time.Millisecond)
+
+	// Validation
+	if stale.Header.Get(CacheStatusHeader) != CacheStale {
+		t.Errorf("Stale response should still be served, got %s", stale.Header.Get(CacheStatusHeader))
+	}
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("No revalidation should start once the client is closed, server got %d requests", hits)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+	defer server.Close()
+
+	c, _ := newCachedTestClient(false)
+
+	// Execution
+	getCached(t, c, server.URL, nil)
+	resp, err := c.PUT(server.URL, nil, "updated")
+	if err != nil {
+		t.Fatalf("Error executing PUT: %v", err)
+	}
+	resp.Body.Close()
+	after, _ := getCached(t, c, server.URL, nil)
+
+	// Validation
+	if after.Header.Get(CacheStatusHeader) != CacheMiss {
+		t.Error("Cached response should be invalidated by a PUT")
+	}
+	if atomic.LoadInt32(&hits) != 3 {
+		t.Errorf("Server got %d requests", hits)
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+
+	// Initialization
+	cache := NewMemoryCache(20)
+
+	// Execution
+	cache.Set("a", []byte("123456789"))
+	cache.Set("b", []byte("123456789"))
+	cache.Get("a")
+	cache.Set("c", []byte("123456789"))
+
+	// Validation
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Least recently used entry should be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("Recently used entry should be kept")
+	}
+	if cache.Size() != 20 {
+		t.Errorf("Invalid size %d", cache.Size())
+	}
+
+	cache.Set("big", make([]byte, 100))
+	if _, ok := cache.Get("big"); ok {
+		t.Error("Entries bigger than the cache should not be stored")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+
+	// Initialization
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Cannot create disk cache. %v", err)
+	}
+
+	// Execution
+	cache.Set("GET https://example.com", []byte("value"))
+	value, ok := cache.Get("GET https://example.com")
+	cache.Delete("GET https://example.com")
+	_, okAfterDelete := cache.Get("GET https://example.com")
+
+	// Validation
+	if !ok || string(value) != "value" {
+		t.Errorf("Invalid stored value %q", value)
+	}
+	if okAfterDelete {
+		t.Error("Deleted value was returned")
+	}
+}
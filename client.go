@@ -1,6 +1,7 @@
 package gohttpclient
 
 import (
+	"context"
 	"net/http"
 	"sync"
 
@@ -20,44 +21,131 @@ type Client interface {
 	TRACE(url string, headers http.Header) (*http.Response, error)
 }
 
+// Doer performs requests bound to a context. Helpers like NewEventSource, NewPaginator,
+// Download and ExecuteBatch only need a Doer, so they're easy to test with a fake one.
+type Doer interface {
+	// Do performs a request with any method, bound to ctx.
+	// Cancelling ctx aborts the request, and per-request options like
+	// WithRouteTemplate are read from it.
+	Do(ctx context.Context, method string, url string, headers http.Header, body interface{}) (*http.Response, error)
+}
+
+// ContextClient is the Client built by ClientBuilder. It's kept apart from Client
+// so existing implementations and mocks of Client still satisfy it.
+type ContextClient interface {
+	Client
+	Doer
+
+	// Stats returns a snapshot of the state of the client endpoints.
+	Stats() ClientStats
+
+	// Close stops the background work of the client, like health checks.
+	// The client can still be used afterwards.
+	Close() error
+}
+
 type client struct {
 	httpClient httpcore.HttpClient //*http.Client. Only one http client is created and can be reused on every call
 	builder    *clientBuilder
 	clientOnce sync.Once
+
+	// streamingClient shares the transport of httpClient, without its total timeout
+	streamingClient httpcore.HttpClient
+
+	digestAuth       *digestAuth
+	responseCache    *responseCache
+	requestCoalescer *requestCoalescer
+	hedger           *hedger
+	endpointPool     *endpointPool
+	healthChecker    *healthChecker
+	hostResolver     *hostResolver
+
+	bandwidthLimiter  *bandwidthLimiter
+	connectionLimiter *bandwidthLimiter
+	requestLimiter    *tokenBucket
+
+	// stop is closed to stop the background goroutines of the client.
+	// backgroundMutex guards closed, so no goroutine is added once Close waits for them.
+	stop            chan struct{}
+	background      sync.WaitGroup
+	backgroundMutex sync.Mutex
+	closed          bool
+	closeOnce       sync.Once
 }
 
 func (c *client) GET(url string, headers http.Header) (*http.Response, error) {
-	return c.do(http.MethodGet, url, headers, nil)
+	return c.do(context.Background(), http.MethodGet, url, headers, nil)
 }
 
 func (c *client) POST(url string, headers http.Header, body interface{}) (*http.Response, error) {
-	return c.do(http.MethodPost, url, headers, body)
+	return c.do(context.Background(), http.MethodPost, url, headers, body)
 }
 
 func (c *client) PUT(url string, headers http.Header, body interface{}) (*http.Response, error) {
-	return c.do(http.MethodPut, url, headers, body)
+	return c.do(context.Background(), http.MethodPut, url, headers, body)
 }
 
 func (c *client) PATCH(url string, headers http.Header, body interface{}) (*http.Response, error) {
-	return c.do(http.MethodPatch, url, headers, body)
+	return c.do(context.Background(), http.MethodPatch, url, headers, body)
 }
 
 func (c *client) DELETE(url string, headers http.Header) (*http.Response, error) {
-	return c.do(http.MethodDelete, url, headers, nil)
+	return c.do(context.Background(), http.MethodDelete, url, headers, nil)
 }
 
 func (c *client) OPTIONS(url string, headers http.Header) (*http.Response, error) {
-	return c.do(http.MethodOptions, url, headers, nil)
+	return c.do(context.Background(), http.MethodOptions, url, headers, nil)
 }
 
 func (c *client) HEAD(url string, headers http.Header) (*http.Response, error) {
-	return c.do(http.MethodHead, url, headers, nil)
+	return c.do(context.Background(), http.MethodHead, url, headers, nil)
 }
 
 func (c *client) CONNECT(url string, headers http.Header) (*http.Response, error) {
-	return c.do(http.MethodConnect, url, headers, nil)
+	return c.do(context.Background(), http.MethodConnect, url, headers, nil)
 }
 
 func (c *client) TRACE(url string, headers http.Header) (*http.Response, error) {
-	return c.do(http.MethodTrace, url, headers, nil)
+	return c.do(context.Background(), http.MethodTrace, url, headers, nil)
+}
+
+func (c *client) Do(ctx context.Context, method string, url string, headers http.Header, body interface{}) (*http.Response, error) {
+	return c.do(ctx, method, url, headers, body)
+}
+
+func (c *client) Stats() ClientStats {
+	var stats ClientStats
+	if c.endpointPool != nil {
+		stats.Endpoints = c.endpointPool.stats()
+	}
+	return stats
+}
+
+func (c *client) Close() error {
+	c.closeOnce.Do(func() {
+		c.backgroundMutex.Lock()
+		c.closed = true
+		if c.stop != nil {
+			close(c.stop)
+		}
+		c.backgroundMutex.Unlock()
+		c.background.Wait()
+	})
+	return nil
+}
+
+// runInBackground runs f in a goroutine Close waits for. f must return once c.stop is closed.
+// It returns false, without running f, when the client is closed.
+func (c *client) runInBackground(f func()) bool {
+	c.backgroundMutex.Lock()
+	defer c.backgroundMutex.Unlock()
+	if c.closed {
+		return false
+	}
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+		f()
+	}()
+	return true
 }
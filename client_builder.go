@@ -1,8 +1,10 @@
 package gohttpclient
 
 import (
+	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -14,11 +16,11 @@ type ClientBuilder interface {
 	SetHeaders(headers http.Header) ClientBuilder
 
 	// SetConnectionTimeout sets the request connection timeout.
-	// Default is 10 seconds.
+	// Default is 30 seconds.
 	SetConnectionTimeout(connectionTimeout time.Duration) ClientBuilder
 
 	// SetResponseTimeout sets the response timeout after we have sent the Request.
-	// Default is 30 seconds.
+	// Default is 10 seconds.
 	// ResponseHeaderTimeout, if non-zero, specifies the amount of
 	// time to wait for a server's response headers after fully
 	// writing the request (including its body, if any). This
@@ -38,7 +40,8 @@ type ClientBuilder interface {
 	SetExpectContinueTimeout(timeout time.Duration) ClientBuilder
 
 	// TLSHandshakeTimeout specifies the maximum amount of time waiting to
-	// wait for a TLS handshake. Zero means no timeout. Default 10 seconds.
+	// wait for a TLS handshake. Zero means no timeout.
+	// Default is 10 seconds.
 	SetTLSHandshakeTimeout(timeout time.Duration) ClientBuilder
 
 	// IdleConnTimeout controls how long an idle connection is kept in the connection pool.
@@ -47,7 +50,7 @@ type ClientBuilder interface {
 	// (keep-alive) connection will remain idle before closing
 	// itself.
 	// Zero means no limit.
-	// Default 90 seconds.
+	// Default is 90 seconds.
 	SetIdleConnTimeout(timeout time.Duration) ClientBuilder
 
 	// MaxIdleConns controls the maximum number of idle (keep-alive)
@@ -59,7 +62,7 @@ type ClientBuilder interface {
 	// (keep-alive) connections to keep per-host. If zero,
 	// standard library default is used.
 	// Requests per minute is a good metric to set this value.
-	// Default is 100.
+	// Default is 20.
 	SetMaxIdleConnectionsPerHost(maxIdleConnsPerHost int) ClientBuilder
 
 	// MaxConnsPerHost optionally limits the total number of
@@ -76,6 +79,7 @@ type ClientBuilder interface {
 	// system. Network protocols or operating systems that do
 	// not support keep-alives ignore this field.
 	// If negative, keep-alive probes are disabled.
+	// Default is 30 seconds.
 	SetDialerKeepAlive(keepTime time.Duration) ClientBuilder
 
 	// FallbackDelay specifies the length of time to wait before
@@ -86,6 +90,7 @@ type ClientBuilder interface {
 	//
 	// If zero, a default delay of 300ms is used.
 	// A negative value disables Fast Fallback support.
+	// Default is 300 milliseconds.
 	SetDialerFallbackDelay(delay time.Duration) ClientBuilder
 
 	// LocalAddr is the local address to use when dialing an
@@ -99,6 +104,7 @@ type ClientBuilder interface {
 	// By default, use of any those fields conservatively disables HTTP/2.
 	// To use a custom dialer or TLS config and still attempt HTTP/2
 	// upgrades, set this to true.
+	// Default is true.
 	ForceAttemptHTTP2(enable bool) ClientBuilder
 
 	// A CookieJar manages storage and use of cookies in HTTP requests.
@@ -107,9 +113,173 @@ type ClientBuilder interface {
 	// The net/http/cookiejar package provides a CookieJar implementation.
 	SetCookieJar(cookieJar http.CookieJar) ClientBuilder
 
+	// SetTLSConfig sets the TLS configuration of connections, like the CAs trusted
+	// to verify servers, or the certificate sent to them.
+	// Default is the Go default configuration, verifying servers against the system CAs.
+	SetTLSConfig(config *tls.Config) ClientBuilder
+
+	// SetProxy sends every request through the proxy at proxyURL.
+	// Default is the proxy set by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables, if any.
+	SetProxy(proxyURL *url.URL) ClientBuilder
+
+	// SetRequestSigner sets a signer that runs on every request once its headers
+	// and body are final, right before it's sent.
+	// HMACSigner and AWSV4Signer are provided.
+	SetRequestSigner(signer RequestSigner) ClientBuilder
+
+	// SetDigestAuth enables HTTP Digest authentication (RFC 7616) with the given credentials.
+	// When a request gets a 401 with a Digest challenge it's sent again authorized.
+	// The challenge is kept by each built Client to authorize following requests
+	// without a new round-trip. MD5, SHA-256 and SHA-512-256 algorithms are supported,
+	// with qop=auth or no qop.
+	SetDigestAuth(username, password string) ClientBuilder
+
+	// SetTimingsHandler sets a callback receiving the lifecycle timings (DNS, connect,
+	// TLS, time to first byte, total) of every request sent.
+	// Timings are also available from each response with GetRequestTimings.
+	SetTimingsHandler(handler TimingsHandler) ClientBuilder
+
+	// SetMetricsRecorder sets where request counts, latencies, in-flight requests and
+	// errors are reported, labeled by method, host, route and status class.
+	// InMemoryMetrics is provided, and can be scraped by Prometheus.
+	SetMetricsRecorder(recorder MetricsRecorder) ClientBuilder
+
+	// SetTracer sets where the span of every request sent is reported, with its URL
+	// redacted by the redaction policy.
+	// The parent span is read from the request context, see ContextWithSpanContext,
+	// and propagated with traceparent and tracestate headers, which happens even
+	// when no tracer is set. Default is NoopTracer.
+	SetTracer(tracer Tracer) ClientBuilder
+
+	// SetLogger sets where every request sent is logged, and how much of it.
+	// Default level is LogOff. NewStdLogger adapts a *log.Logger.
+	SetLogger(logger RequestLogger, level LogLevel) ClientBuilder
+
+	// SetRedactionPolicy sets the headers, query params and JSON body fields
+	// hidden from logs. Default is DefaultRedactionPolicy.
+	SetRedactionPolicy(policy RedactionPolicy) ClientBuilder
+
+	// SetLogBodyLimit sets the maximum number of body bytes logged on LogBodies level.
+	// Default is 4096.
+	SetLogBodyLimit(limit int) ClientBuilder
+
+	// LogCurlOnFailure adds to the log entry of every failed request, be it a transport
+	// error or a 4xx/5xx status, the curl command reproducing it, redacted with the
+	// redaction policy. It requires a logger. Curl commands of single requests can be
+	// obtained with WithCurlHandler or CurlCommand too.
+	// Default is false.
+	LogCurlOnFailure(enable bool) ClientBuilder
+
+	// SetCache enables an RFC 9111 cache of GET responses kept in storage.
+	// It honors Cache-Control directives, Expires and Vary, revalidates stale responses
+	// with If-None-Match and If-Modified-Since, and supports stale-while-revalidate and
+	// stale-if-error. A private cache keeps responses per Authorization, Proxy-Authorization
+	// and Cookie values, and unsafe requests only invalidate the response kept for their
+	// own credentials. A shared cache doesn't store private responses nor, unless allowed,
+	// responses to authorized requests. Every response to a GET gets an X-Cache header
+	// telling how it was served.
+	// MemoryCache and DiskCache storages are provided. Default is no cache.
+	SetCache(storage CacheStorage, shared bool) ClientBuilder
+
+	// SetRequestCoalescing makes concurrent identical GET, HEAD and OPTIONS requests
+	// share a single round-trip. Requests are identical when their method, URL,
+	// Authorization, Proxy-Authorization and Cookie headers and the values of keyHeaders
+	// match. The round-trip doesn't use the curl handler, progress callback, bandwidth
+	// limit nor span of any caller's context. Every caller gets its own copy of the
+	// response, whose body is read in full in memory.
+	// Default is false.
+	SetRequestCoalescing(enable bool, keyHeaders ...string) ClientBuilder
+
+	// SetHedgePolicy sends a second copy of idempotent requests not answered after the
+	// policy delay, takes the first response and cancels the other request.
+	// Streamed requests aren't hedged.
+	// The policy can be overridden per request with WithHedgePolicy.
+	// Default is no hedging.
+	SetHedgePolicy(policy HedgePolicy) ClientBuilder
+
+	// SetRetryPolicy sends requests failing with a connection error or a 429, 502, 503
+	// or 504 response again, waiting an exponential backoff between attempts.
+	// Requests that may not be idempotent are only retried when the server never got them.
+	// Default is no retries.
+	SetRetryPolicy(policy RetryPolicy) ClientBuilder
+
+	// SetBaseURL sets the base URL, like "https://api.example.com/v1", requests with
+	// a relative URL, like "/users/1", are sent to. It must not be set together with
+	// SetEndpoints or SetEndpointSource, which take precedence.
+	// Default is no base URL.
+	SetBaseURL(baseURL string) ClientBuilder
+
+	// SetEndpoints sets the base URLs, like "https://replica-1.example.com/api", requests
+	// with a relative URL, like "/users/1", are sent to. Requests are balanced among
+	// them, and sent to another one on connection errors or 502, 503 and 504 responses
+	// when the request is idempotent. Absolute URLs are not affected.
+	// Default is no endpoints.
+	SetEndpoints(balancing Balancing, baseURLs ...string) ClientBuilder
+
+	// SetEndpointSource works like SetEndpoints, getting the endpoints from source, like
+	// an SRVSource, and refreshing them in the background until the client is closed.
+	SetEndpointSource(balancing Balancing, source EndpointSource) ClientBuilder
+
+	// SetOutlierEjection leaves an endpoint out of balancing for ejectionTime after
+	// consecutiveFailures connection errors or 5xx responses in a row. Ejected endpoints
+	// are still used if every endpoint is ejected. Zero consecutiveFailures disables it.
+	// Default is 5 failures and 30 seconds.
+	SetOutlierEjection(consecutiveFailures int, ejectionTime time.Duration) ClientBuilder
+
+	// SetHealthCheck periodically probes a path on the base URL or every endpoint,
+	// leaving the unhealthy ones out of balancing. Probes carry the headers set with
+	// SetHeaders and are signed like every request. They run in the background until
+	// the client is closed.
+	// Default is no health checks.
+	SetHealthCheck(check HealthCheck) ClientBuilder
+
+	// SetHostOverrides makes connections to the hosts in overrides go to the address
+	// they're mapped to, like "api.example.com" to "10.0.0.5", skipping DNS.
+	// TLS is still verified against the original host.
+	// Default is no overrides.
+	SetHostOverrides(overrides map[string]string) ClientBuilder
+
+	// SetResolver sets the resolver used to look up hosts, like one querying
+	// a specific DNS server.
+	// Default is the system resolver.
+	SetResolver(resolver *net.Resolver) ClientBuilder
+
+	// SetDNSCache caches in process the addresses hosts resolve to for ttl,
+	// and failed lookups of hosts not found for negativeTTL.
+	// Default is no cache.
+	SetDNSCache(ttl time.Duration, negativeTTL time.Duration) ClientBuilder
+
+	// SetBandwidthLimit limits the bytes per second of request body uploads and response
+	// body downloads, shared by all the requests of the client.
+	// WithBandwidthLimit adds limits to single requests.
+	// Default is no limit.
+	SetBandwidthLimit(limit BandwidthLimit) ClientBuilder
+
+	// SetConnectionBandwidthLimit limits the bytes per second read from and written to
+	// the connections of the client, shared by all of them. Unlike SetBandwidthLimit,
+	// it covers everything sent, like headers and TLS handshakes.
+	// Default is no limit.
+	SetConnectionBandwidthLimit(limit BandwidthLimit) ClientBuilder
+
+	// SetRequestRateLimit limits the requests sent per second, like 0.5 for one request
+	// every 2 seconds, shared by all the requests of the client, including retries,
+	// hedged requests and batches. Up to burst requests are sent at once after an idle
+	// time, requests being evenly spaced when burst is 1 or less.
+	// Default is no limit.
+	SetRequestRateLimit(requestsPerSecond float64, burst int) ClientBuilder
+
 	// Build sets the previously configured parameters into our HTTP client
 	// and returns it to perform the desired HTTP calls.
-	Build() Client
+	Build() ContextClient
+
+	// Validate checks the configured parameters and how they interact, like pool sizes
+	// or a local address the dialer can't use, returning a ConfigError listing every
+	// problem found.
+	Validate() error
+
+	// BuildE builds the client like Build, only if Validate finds no problem.
+	BuildE() (ContextClient, error)
 }
 
 type clientBuilder struct {
@@ -133,36 +303,130 @@ type clientBuilder struct {
 	headers http.Header
 
 	cookieJar http.CookieJar
+
+	tlsConfig *tls.Config
+	proxyURL  *url.URL
+
+	requestSigner RequestSigner
+
+	digestAuthEnabled bool
+	digestUsername    string
+	digestPassword    string
+
+	timingsHandler TimingsHandler
+
+	metricsRecorder MetricsRecorder
+
+	tracer Tracer
+
+	logger          RequestLogger
+	logLevel        LogLevel
+	redactionPolicy RedactionPolicy
+	logBodyLimit    int
+	curlOnFailure   bool
+
+	cacheStorage CacheStorage
+	sharedCache  bool
+
+	coalescingEnabled    bool
+	coalescingKeyHeaders []string
+
+	hedgePolicy HedgePolicy
+	retryPolicy RetryPolicy
+
+	baseURL          string
+	balancing        Balancing
+	endpoints        []string
+	endpointSource   EndpointSource
+	ejectionFailures int
+	ejectionTime     time.Duration
+
+	healthCheck *HealthCheck
+
+	hostOverrides    map[string]string
+	resolver         *net.Resolver
+	dnsCacheTTL      time.Duration
+	negativeCacheTTL time.Duration
+
+	bandwidthLimit           BandwidthLimit
+	connectionBandwidthLimit BandwidthLimit
+	requestRate              float64
+	requestBurst             int
 }
 
 // NewBuiler returns a ClientBuilder that you can configure to build
-// finally your HTTP client.
+// finally your HTTP client. It starts from the settings of DefaultConfig.
 func NewBuilder() ClientBuilder {
+	defaults := DefaultConfig()
 	return &clientBuilder{
 
-		connectionTimeout:     defaultConnectionTimeout,
-		responseTimeOut:       defaultResponseTimeOut,
-		expectContinueTimeout: defaultExpectContinueTimeout,
-		tlsHandshakeTimeout:   defaultTLSHandshakeTimeout,
+		connectionTimeout:     defaults.ConnectionTimeout,
+		responseTimeOut:       defaults.ResponseTimeout,
+		expectContinueTimeout: defaults.ExpectContinueTimeout,
+		tlsHandshakeTimeout:   defaults.TLSHandshakeTimeout,
 
-		idleConnTimeout: defaultIdleConnTimeout,
+		idleConnTimeout: defaults.IdleConnTimeout,
 
-		maxIdleConns: defaultMaxIdleConnections,
+		maxIdleConns:        defaults.MaxIdleConnections,
+		maxIdleConnsPerHost: defaults.MaxIdleConnectionsPerHost,
+		maxConnsPerHost:     defaults.MaxConnectionsPerHost,
 
-		keepAliveTime: defaultKeepAliveTime,
-		fallbackDelay: defaultFallbackDelay,
+		keepAliveTime: defaults.DialerKeepAlive,
+		fallbackDelay: defaults.DialerFallbackDelay,
 		localAddr:     nil,
 
-		forceAttemptHTTP2Enabled: defaultForceAttemptHTTP2Enabled,
+		forceAttemptHTTP2Enabled: !defaults.DisableHTTP2,
 
 		cookieJar: nil,
+
+		redactionPolicy: DefaultRedactionPolicy(),
+		logBodyLimit:    defaultLogBodyLimit,
+
+		ejectionFailures: defaultEjectionFailures,
+		ejectionTime:     defaultEjectionTime,
 	}
 }
 
-func (b *clientBuilder) Build() Client {
-	return &client{
+func (b *clientBuilder) Build() ContextClient {
+	c := &client{
 		builder: b,
+		stop:    make(chan struct{}),
+	}
+	if b.digestAuthEnabled {
+		c.digestAuth = newDigestAuth(b.digestUsername, b.digestPassword)
+	}
+	if b.cacheStorage != nil {
+		c.responseCache = newResponseCache(b.cacheStorage, b.sharedCache)
 	}
+	if b.coalescingEnabled {
+		c.requestCoalescer = newRequestCoalescer(b.coalescingKeyHeaders)
+	}
+	c.hedger = newHedger()
+	if len(b.hostOverrides) > 0 || b.dnsCacheTTL > 0 || b.negativeCacheTTL > 0 {
+		c.hostResolver = newHostResolver(b.hostOverrides, b.resolver, b.dnsCacheTTL, b.negativeCacheTTL)
+	}
+	c.bandwidthLimiter = newBandwidthLimiter(b.bandwidthLimit)
+	c.connectionLimiter = newBandwidthLimiter(b.connectionBandwidthLimit)
+	c.requestLimiter = newRequestRateLimiter(b.requestRate, b.requestBurst)
+	c.setupHttpClient()
+	// The base URL is sent to as the single endpoint
+	endpoints := b.endpoints
+	if len(endpoints) == 0 && b.endpointSource == nil && b.baseURL != "" {
+		endpoints = []string{b.baseURL}
+	}
+	if len(endpoints) > 0 || b.endpointSource != nil {
+		c.endpointPool = newEndpointPool(b.balancing, b.ejectionFailures, b.ejectionTime)
+		if b.endpointSource != nil {
+			c.startDiscovery(b.endpointSource)
+		} else {
+			c.endpointPool.setEndpoints(newStaticEndpoints(endpoints))
+		}
+		if b.healthCheck != nil {
+			c.healthChecker = newHealthChecker(*b.healthCheck)
+			c.startHealthChecks()
+		}
+	}
+	return c
 }
 
 func (b *clientBuilder) SetHeaders(headers http.Header) ClientBuilder {
@@ -234,3 +498,143 @@ func (b *clientBuilder) SetCookieJar(cookieJar http.CookieJar) ClientBuilder {
 	b.cookieJar = cookieJar
 	return b
 }
+
+func (b *clientBuilder) SetTLSConfig(config *tls.Config) ClientBuilder {
+	b.tlsConfig = config
+	return b
+}
+
+func (b *clientBuilder) SetProxy(proxyURL *url.URL) ClientBuilder {
+	b.proxyURL = proxyURL
+	return b
+}
+
+func (b *clientBuilder) SetRequestSigner(signer RequestSigner) ClientBuilder {
+	b.requestSigner = signer
+	return b
+}
+
+func (b *clientBuilder) SetDigestAuth(username, password string) ClientBuilder {
+	b.digestAuthEnabled = true
+	b.digestUsername = username
+	b.digestPassword = password
+	return b
+}
+
+func (b *clientBuilder) SetTimingsHandler(handler TimingsHandler) ClientBuilder {
+	b.timingsHandler = handler
+	return b
+}
+
+func (b *clientBuilder) SetMetricsRecorder(recorder MetricsRecorder) ClientBuilder {
+	b.metricsRecorder = recorder
+	return b
+}
+
+func (b *clientBuilder) SetTracer(tracer Tracer) ClientBuilder {
+	b.tracer = tracer
+	return b
+}
+
+func (b *clientBuilder) SetLogger(logger RequestLogger, level LogLevel) ClientBuilder {
+	b.logger = logger
+	b.logLevel = level
+	return b
+}
+
+func (b *clientBuilder) SetRedactionPolicy(policy RedactionPolicy) ClientBuilder {
+	b.redactionPolicy = policy
+	return b
+}
+
+func (b *clientBuilder) SetLogBodyLimit(limit int) ClientBuilder {
+	b.logBodyLimit = limit
+	return b
+}
+
+func (b *clientBuilder) LogCurlOnFailure(enable bool) ClientBuilder {
+	b.curlOnFailure = enable
+	return b
+}
+
+func (b *clientBuilder) SetCache(storage CacheStorage, shared bool) ClientBuilder {
+	b.cacheStorage = storage
+	b.sharedCache = shared
+	return b
+}
+
+func (b *clientBuilder) SetRequestCoalescing(enable bool, keyHeaders ...string) ClientBuilder {
+	b.coalescingEnabled = enable
+	b.coalescingKeyHeaders = keyHeaders
+	return b
+}
+
+func (b *clientBuilder) SetHedgePolicy(policy HedgePolicy) ClientBuilder {
+	b.hedgePolicy = policy
+	return b
+}
+
+func (b *clientBuilder) SetRetryPolicy(policy RetryPolicy) ClientBuilder {
+	b.retryPolicy = policy
+	return b
+}
+
+func (b *clientBuilder) SetBaseURL(baseURL string) ClientBuilder {
+	b.baseURL = baseURL
+	return b
+}
+
+func (b *clientBuilder) SetEndpoints(balancing Balancing, baseURLs ...string) ClientBuilder {
+	b.balancing = balancing
+	b.endpoints = baseURLs
+	return b
+}
+
+func (b *clientBuilder) SetEndpointSource(balancing Balancing, source EndpointSource) ClientBuilder {
+	b.balancing = balancing
+	b.endpointSource = source
+	return b
+}
+
+func (b *clientBuilder) SetOutlierEjection(consecutiveFailures int, ejectionTime time.Duration) ClientBuilder {
+	b.ejectionFailures = consecutiveFailures
+	b.ejectionTime = ejectionTime
+	return b
+}
+
+func (b *clientBuilder) SetHealthCheck(check HealthCheck) ClientBuilder {
+	b.healthCheck = &check
+	return b
+}
+
+func (b *clientBuilder) SetHostOverrides(overrides map[string]string) ClientBuilder {
+	b.hostOverrides = overrides
+	return b
+}
+
+func (b *clientBuilder) SetResolver(resolver *net.Resolver) ClientBuilder {
+	b.resolver = resolver
+	return b
+}
+
+func (b *clientBuilder) SetDNSCache(ttl time.Duration, negativeTTL time.Duration) ClientBuilder {
+	b.dnsCacheTTL = ttl
+	b.negativeCacheTTL = negativeTTL
+	return b
+}
+
+func (b *clientBuilder) SetBandwidthLimit(limit BandwidthLimit) ClientBuilder {
+	b.bandwidthLimit = limit
+	return b
+}
+
+func (b *clientBuilder) SetConnectionBandwidthLimit(limit BandwidthLimit) ClientBuilder {
+	b.connectionBandwidthLimit = limit
+	return b
+}
+
+func (b *clientBuilder) SetRequestRateLimit(requestsPerSecond float64, burst int) ClientBuilder {
+	b.requestRate = requestsPerSecond
+	b.requestBurst = burst
+	return b
+}
@@ -2,31 +2,20 @@ package gohttpclient
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net"
 	"net/http"
-	"time"
 
+	"github.com/maxiancillotti/gohttpclient/httpcore"
 	"github.com/maxiancillotti/gohttpclient/mock"
 )
 
 const (
-	defaultResponseTimeOut       time.Duration = 10 * time.Second
-	defaultConnectionTimeout     time.Duration = 30 * time.Second
-	defaultFallbackDelay         time.Duration = 300 * time.Millisecond
-	defaultExpectContinueTimeout time.Duration = 1 * time.Second
-	defaultTLSHandshakeTimeout   time.Duration = 10 * time.Second
-
-	defaultKeepAliveTime       time.Duration = 30 * time.Second
-	defaultIdleConnTimeout     time.Duration = 90 * time.Second
-	defaultMaxIdleConnections  int           = 100
-	defaultMaxIdleConnsPerHost int           = 20
-	defaultMaxConnsPerHost     int           = 512
-
-	defaultForceAttemptHTTP2Enabled bool = true
+	defaultLogBodyLimit int = 4096
 )
 
-func (c *client) do(method string, url string, headers http.Header, body interface{}) (*http.Response, error) {
+func (c *client) do(ctx context.Context, method string, url string, headers http.Header, body interface{}) (*http.Response, error) {
 
 	fullHeaders := c.getRequestHeaders(headers)
 	c.addDefaultRequestHeaders(&fullHeaders)
@@ -36,38 +25,172 @@ func (c *client) do(method string, url string, headers http.Header, body interfa
 		return nil, fmt.Errorf("unable to marshal body. %v", err)
 	}
 
-	request, err := http.NewRequest(method, url, bytes.NewBuffer(marshaledBody))
+	var response *http.Response
+	if c.requestCoalescer != nil && isCoalescable(method) && !isStreaming(ctx) {
+		response, err = c.sendCoalesced(ctx, method, url, fullHeaders, marshaledBody)
+	} else {
+		response, err = c.exchange(ctx, method, url, fullHeaders, marshaledBody)
+	}
+	if err == nil {
+		trackDownload(ctx, response)
+		c.limitDownload(ctx, response)
+	}
+	return response, err
+}
+
+// exchange gets the response to a request, hedging it if a policy is set and it isn't streamed.
+func (c *client) exchange(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+
+	if c.hedger != nil && isHedgeable(method) && !isStreaming(ctx) {
+		if policy := c.hedgePolicy(ctx); policy.enabled() {
+			return c.sendHedged(ctx, policy, method, url, headers, body)
+		}
+	}
+	return c.fetch(ctx, method, url, headers, body)
+}
+
+// fetch gets the response to a request from the cache, if set, or the server.
+func (c *client) fetch(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+
+	if c.responseCache != nil && !isStreaming(ctx) {
+		return c.sendCached(ctx, method, url, headers, body)
+	}
+	return c.roundTrip(ctx, method, url, headers, body)
+}
+
+// roundTrip gets the response to a request, sending it again as allowed by the
+// retry policy.
+func (c *client) roundTrip(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+	if c.builder.retryPolicy.MaxRetries > 0 {
+		return c.sendWithRetries(ctx, method, url, headers, body)
+	}
+	return c.dispatch(ctx, method, url, headers, body)
+}
+
+// dispatch gets the response to a request from the server, or from an endpoint
+// when the URL is relative.
+func (c *client) dispatch(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+
+	if c.endpointPool != nil && isRelativeURL(url) {
+		return c.sendBalanced(ctx, method, url, headers, body)
+	}
+	return c.sendTo(ctx, method, url, headers, body)
+}
+
+// sendTo gets the response to a request from the server at url,
+// answering authentication challenges if needed.
+func (c *client) sendTo(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+
+	if c.digestAuth != nil {
+		return c.sendWithDigestAuth(ctx, method, url, headers, body)
+	}
+
+	request, err := c.newRequest(ctx, method, url, headers, body)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create new request")
+		return nil, err
+	}
+	return c.send(request)
+}
+
+// send performs a single round-trip of an already prepared request.
+// Every request going out of the client, including resends, goes through here.
+func (c *client) send(request *http.Request) (*http.Response, error) {
+
+	if c.requestLimiter != nil {
+		if err := c.requestLimiter.wait(request.Context(), 1); err != nil {
+			return nil, err
+		}
+	}
+
+	request, timer := traceRequest(request)
+
+	metrics := c.builder.metricsRecorder
+	labels := newMetricLabels(request)
+	if metrics != nil {
+		metrics.RequestStarted(labels)
 	}
-	request.Header = fullHeaders
 
-	c.setupHttpClient()
+	span := c.startSpan(request)
 
-	return c.httpClient.Do(request)
+	response, err := c.getHttpClient(request.Context()).Do(request)
+
+	c.endSpan(span, response, err)
+
+	timings := timer.finish()
+	if c.builder.timingsHandler != nil {
+		c.builder.timingsHandler(request, timings)
+	}
+	if metrics != nil {
+		labels.StatusClass = statusClass(response, err)
+		metrics.RequestFinished(labels, timings.Total, err)
+	}
+	failed := err != nil || response.StatusCode >= http.StatusBadRequest
+	c.logRequest(request, response, err, timings.Total, c.curlCommand(request, failed))
+
+	return response, err
 }
 
-func (c *client) setupHttpClient() {
+// newRequest creates a request ready to be sent, signed if a signer is set.
+// Every call gets its own copy of headers and body so requests can be sent again.
+func (c *client) newRequest(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Request, error) {
+
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new request")
+	}
+	request.Header = headers.Clone()
+	trackUpload(request)
+	c.limitUpload(request)
+
+	request, err = c.injectTraceContext(request)
+	if err != nil {
+		return nil, err
+	}
 
+	if c.builder.requestSigner != nil {
+		if err := c.builder.requestSigner.Sign(request, body); err != nil {
+			return nil, fmt.Errorf("unable to sign request. %v", err)
+		}
+	}
+	return request, nil
+}
+
+// getHttpClient returns the client sending requests with ctx: the mockup one when the
+// mockup server is enabled, else the one without total timeout for streamed requests.
+func (c *client) getHttpClient(ctx context.Context) httpcore.HttpClient {
 	if mock.MockupServer.IsEnabled() {
-		c.httpClient = mock.MockupServer.GetClient()
-		return
+		return mock.MockupServer.GetClient()
 	}
-	c.clientOnce.Do(func() {
+	if isStreaming(ctx) {
+		return c.streamingClient
+	}
+	return c.httpClient
+}
 
-		// Covers the entire exchange, from Dial (if a connection is not reused) to reading the body
-		totalTimeout := c.builder.expectContinueTimeout + c.builder.tlsHandshakeTimeout + c.builder.connectionTimeout + c.builder.responseTimeOut
+// setupHttpClient creates the http clients. It's called by Build, before any goroutine
+// of the client may send requests.
+func (c *client) setupHttpClient() {
+
+	c.clientOnce.Do(func() {
 
 		customTransport := http.DefaultTransport.(*http.Transport).Clone()
 
 		// Dialer contains options for connecting to an address
-		customTransport.DialContext = (&net.Dialer{
+		dialer := &net.Dialer{
 			// Dial Timeout limits the time spent establishing a TCP connection (if a new one is needed)
 			Timeout:       c.builder.connectionTimeout,
 			KeepAlive:     c.builder.keepAliveTime,
 			FallbackDelay: c.builder.fallbackDelay,
 			LocalAddr:     c.builder.localAddr,
-		}).DialContext
+			Resolver:      c.builder.resolver,
+		}
+		customTransport.DialContext = dialer.DialContext
+		if c.hostResolver != nil {
+			customTransport.DialContext = c.hostResolver.dialContext(dialer)
+		}
+		if c.connectionLimiter != nil {
+			customTransport.DialContext = limitedDialContext(customTransport.DialContext, c.connectionLimiter)
+		}
 
 		customTransport.ResponseHeaderTimeout = c.builder.responseTimeOut
 		customTransport.ExpectContinueTimeout = c.builder.expectContinueTimeout
@@ -80,13 +203,25 @@ func (c *client) setupHttpClient() {
 
 		customTransport.ForceAttemptHTTP2 = c.builder.forceAttemptHTTP2Enabled
 
+		if c.builder.tlsConfig != nil {
+			customTransport.TLSClientConfig = c.builder.tlsConfig
+		}
+		if c.builder.proxyURL != nil {
+			customTransport.Proxy = http.ProxyURL(c.builder.proxyURL)
+		}
+
 		c.httpClient = &http.Client{
 			Transport: customTransport,
 			// CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// 	return errors.New("error")
 			// },
 			Jar:     c.builder.cookieJar,
-			Timeout: totalTimeout,
+			Timeout: c.builder.totalTimeout(),
+		}
+		// Same transport, limited only by the request context
+		c.streamingClient = &http.Client{
+			Transport: customTransport,
+			Jar:       c.builder.cookieJar,
 		}
 	})
 }
@@ -0,0 +1,153 @@
+package gohttpclient
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// requestCoalescer makes concurrent identical requests share a single round-trip.
+type requestCoalescer struct {
+	keyHeaders []string
+
+	mutex sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is a round-trip shared by every request waiting for it.
+// It's cancelled once no request is waiting for it anymore.
+type coalescedCall struct {
+	done    chan struct{}
+	waiters int
+	cancel  context.CancelFunc
+
+	response *http.Response
+	body     []byte
+	err      error
+}
+
+// credentialHeaders are always part of the coalescing key, so a response is never
+// shared with a request made on behalf of someone else.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+func newRequestCoalescer(keyHeaders []string) *requestCoalescer {
+	unique := make(map[string]bool)
+	var canonicalHeaders []string
+	for _, header := range append(credentialHeaders, keyHeaders...) {
+		header = http.CanonicalHeaderKey(header)
+		if !unique[header] {
+			unique[header] = true
+			canonicalHeaders = append(canonicalHeaders, header)
+		}
+	}
+	sort.Strings(canonicalHeaders)
+
+	return &requestCoalescer{
+		keyHeaders: canonicalHeaders,
+		calls:      make(map[string]*coalescedCall),
+	}
+}
+
+// isCoalescable tells whether requests with the method can share a response.
+// Only safe methods without body are.
+func isCoalescable(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// sendCoalesced joins the in-flight round-trip of an identical request, or starts one.
+// The response body is read in full by the shared round-trip, and every caller gets
+// its own copy of the response reading from it.
+// A caller giving up doesn't affect the others; the round-trip is cancelled only when
+// every caller gave up.
+func (c *client) sendCoalesced(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+	coalescer := c.requestCoalescer
+	key := coalescer.key(method, url, headers)
+
+	coalescer.mutex.Lock()
+	call, ok := coalescer.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(sharedContext(ctx))
+		call = &coalescedCall{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		coalescer.calls[key] = call
+
+		go func() {
+			defer close(call.done)
+			defer cancel()
+
+			response, err := c.exchange(callCtx, method, url, headers, body)
+			if err == nil {
+				call.body, err = ioutil.ReadAll(response.Body)
+				response.Body.Close()
+			}
+			call.response, call.err = response, err
+
+			coalescer.forget(key, call)
+		}()
+	}
+	call.waiters++
+	coalescer.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return call.copyResponse(), nil
+
+	case <-ctx.Done():
+		coalescer.mutex.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if coalescer.calls[key] == call {
+				delete(coalescer.calls, key)
+			}
+		}
+		coalescer.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// forget removes the call so following requests start a new round-trip.
+func (r *requestCoalescer) forget(key string, call *coalescedCall) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.calls[key] == call {
+		delete(r.calls, key)
+	}
+}
+
+// key identifies identical requests by method, URL, credentials and key headers.
+func (r *requestCoalescer) key(method string, url string, headers http.Header) string {
+	var builder strings.Builder
+	builder.WriteString(method)
+	builder.WriteString(" ")
+	builder.WriteString(url)
+	for _, header := range r.keyHeaders {
+		builder.WriteString("\n")
+		builder.WriteString(header)
+		builder.WriteString(": ")
+		builder.WriteString(strings.Join(headers.Values(header), ", "))
+	}
+	return builder.String()
+}
+
+func (call *coalescedCall) copyResponse() *http.Response {
+	response := *call.response
+	response.Header = call.response.Header.Clone()
+	response.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+	response.ContentLength = int64(len(call.body))
+	return &response
+}
@@ -0,0 +1,194 @@
+package gohttpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForWaiters waits until n requests are waiting for the same coalesced call.
+func waitForWaiters(t *testing.T, c *client, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.requestCoalescer.mutex.Lock()
+		waiters := 0
+		for _, call := range c.requestCoalescer.calls {
+			waiters += call.waiters
+		}
+		c.requestCoalescer.mutex.Unlock()
+		if waiters == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d waiters were expected", n)
+}
+
+func TestRequestCoalescing(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Header().Set("X-Test", "value")
+		w.Write([]byte("shared body"))
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetRequestCoalescing(true).Build().(*client)
+
+	// Execution
+	const callers = 50
+	bodies := make([]string, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := c.GET(server.URL, nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			resp.Header.Set("X-Test", "modified")
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			bodies[i] = string(body)
+		}(i)
+	}
+	waitForWaiters(t, c, callers)
+	close(release)
+	wg.Wait()
+
+	// Validation
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("Server got %d requests", hits)
+	}
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("Error executing GET: %v", errs[i])
+		}
+		if bodies[i] != "shared body" {
+			t.Fatalf("Invalid body %q", bodies[i])
+		}
+	}
+}
+
+func TestRequestCoalescingKeyHeaders(t *testing.T) {
+
+	// Initialization
+	c := &client{requestCoalescer: newRequestCoalescer([]string{"accept-language"})}
+	english := make(http.Header)
+	english.Set("Accept-Language", "en")
+	english.Set("X-Request-Id", "1")
+	spanish := make(http.Header)
+	spanish.Set("Accept-Language", "es")
+	otherEnglish := make(http.Header)
+	otherEnglish.Set("Accept-Language", "en")
+	otherEnglish.Set("X-Request-Id", "2")
+	authorized := english.Clone()
+	authorized.Set("Authorization", "Bearer token")
+
+	// Execution
+	englishKey := c.requestCoalescer.key(http.MethodGet, "https://example.com", english)
+	spanishKey := c.requestCoalescer.key(http.MethodGet, "https://example.com", spanish)
+	otherEnglishKey := c.requestCoalescer.key(http.MethodGet, "https://example.com", otherEnglish)
+	authorizedKey := c.requestCoalescer.key(http.MethodGet, "https://example.com", authorized)
+
+	// Validation
+	if englishKey == spanishKey {
+		t.Error("Requests with different key headers should not be coalesced")
+	}
+	if englishKey != otherEnglishKey {
+		t.Error("Requests differing only in other headers should be coalesced")
+	}
+	if englishKey == authorizedKey {
+		t.Error("Requests with different credentials should not be coalesced")
+	}
+	if isCoalescable(http.MethodPost) {
+		t.Error("POST requests should not be coalesced")
+	}
+}
+
+func TestRequestCoalescingCallerCancellation(t *testing.T) {
+
+	// Initialization
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetRequestCoalescing(true).Build().(*client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error, 1)
+	go func() {
+		_, err := c.Do(ctx, http.MethodGet, server.URL, nil, nil)
+		cancelledErr <- err
+	}()
+	waitForWaiters(t, c, 1)
+
+	patientBody := make(chan string, 1)
+	go func() {
+		resp, err := c.GET(server.URL, nil)
+		if err != nil {
+			patientBody <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		patientBody <- string(body)
+	}()
+	waitForWaiters(t, c, 2)
+
+	// Execution
+	cancel()
+	err := <-cancelledErr
+	close(release)
+
+	// Validation
+	if err != context.Canceled {
+		t.Errorf("Cancelled caller should get its context error, got %v", err)
+	}
+	if body := <-patientBody; body != "body" {
+		t.Errorf("Other callers should not be affected, got %q", body)
+	}
+}
+
+func TestRequestCoalescingCallerValues(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetRequestCoalescing(true).Build()
+	var commands int32
+	ctx := WithCurlHandler(context.Background(), func(string) {
+		atomic.AddInt32(&commands, 1)
+	})
+
+	// Execution
+	resp, err := c.Do(ctx, http.MethodGet, server.URL, nil, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	if atomic.LoadInt32(&commands) != 0 {
+		t.Error("Shared round-trip should not use the curl handler of a caller")
+	}
+}
@@ -0,0 +1,560 @@
+package gohttpclient
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of the environment variables read by LoadConfig. A setting
+// is read from the variable named after its key in upper case, with dots replaced by
+// underscores, like HTTPCLIENT_RESPONSE_TIMEOUT or HTTPCLIENT_TLS_CA_FILE.
+// Headers are read from HTTPCLIENT_HEADERS_<NAME>, underscores in the name being hyphens.
+// Other variables starting with the prefix are ignored.
+const EnvPrefix = "HTTPCLIENT_"
+
+// Config holds the client settings that can be loaded from files and environment
+// variables. Zero values keep the setting of the builder they're applied to, but
+// the ones LoadConfig read, like max_connections_per_host: 0 for no limit.
+// Durations are written like "1.5s" or "300ms".
+type Config struct {
+	ConnectionTimeout     time.Duration `config:"connection_timeout"`
+	ResponseTimeout       time.Duration `config:"response_timeout"`
+	ExpectContinueTimeout time.Duration `config:"expect_continue_timeout"`
+	TLSHandshakeTimeout   time.Duration `config:"tls_handshake_timeout"`
+	IdleConnTimeout       time.Duration `config:"idle_conn_timeout"`
+
+	MaxIdleConnections        int `config:"max_idle_connections"`
+	MaxIdleConnectionsPerHost int `config:"max_idle_connections_per_host"`
+	MaxConnectionsPerHost     int `config:"max_connections_per_host"`
+
+	DialerKeepAlive     time.Duration `config:"dialer_keep_alive"`
+	DialerFallbackDelay time.Duration `config:"dialer_fallback_delay"`
+
+	// MaxRetries, RetryBackoff and RetryMaxBackoff set the retry policy, see RetryPolicy.
+	MaxRetries      int           `config:"max_retries"`
+	RetryBackoff    time.Duration `config:"retry_backoff"`
+	RetryMaxBackoff time.Duration `config:"retry_max_backoff"`
+
+	// DisableHTTP2 stops attempting HTTP/2 connections.
+	DisableHTTP2 bool `config:"disable_http2"`
+
+	// BaseURL relative URLs are resolved against.
+	BaseURL string `config:"base_url"`
+
+	// Headers sent with every request.
+	Headers map[string]string `config:"headers"`
+
+	// ProxyURL every request is sent through, like "http://proxy.internal:3128".
+	ProxyURL string `config:"proxy_url"`
+
+	TLS TLSFiles `config:"tls"`
+
+	// loaded keeps the keys set by LoadConfig, applied even when zero.
+	loaded map[string]bool
+}
+
+// TLSFiles configures TLS connections from PEM files.
+type TLSFiles struct {
+	// CAFile holds the CAs trusted to verify servers, instead of the system ones.
+	CAFile string `config:"ca_file"`
+
+	// CertFile and KeyFile hold the certificate sent to servers asking for one.
+	CertFile string `config:"cert_file"`
+	KeyFile  string `config:"key_file"`
+
+	// ServerName verified in server certificates, instead of the host requested.
+	ServerName string `config:"server_name"`
+
+	// InsecureSkipVerify accepts any server certificate. Only meant for testing.
+	InsecureSkipVerify bool `config:"insecure_skip_verify"`
+}
+
+// DefaultConfig returns the settings NewBuilder starts from, also documented on each
+// ClientBuilder method. Every call returns a new copy, which can be modified and passed
+// to NewBuilderFromConfig. It sets no base URL, headers, proxy URL nor TLS files.
+func DefaultConfig() Config {
+	return Config{
+		ConnectionTimeout:     30 * time.Second,
+		ResponseTimeout:       10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+
+		MaxIdleConnections:        100,
+		MaxIdleConnectionsPerHost: 20,
+		MaxConnectionsPerHost:     512,
+
+		DialerKeepAlive:     30 * time.Second,
+		DialerFallbackDelay: 300 * time.Millisecond,
+	}
+}
+
+// ConfigError lists the problems found in a configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration. " + strings.Join(e.Problems, "; ")
+}
+
+// LoadConfig loads the configuration in the file at path, if not empty, overridden by
+// the environment variables starting with EnvPrefix, and validates it like
+// NewBuilderFromConfig does.
+// Files ending in .json are read as JSON objects, and files ending in .yaml or .yml
+// as YAML made of "key: value" lines, nested by indentation.
+// Keys are the config tags of Config fields, like response_timeout.
+// Every problem found is reported in a ConfigError.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	var problems []string
+
+	if path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		problems = append(problems, config.setValues(values, path)...)
+	}
+	problems = append(problems, config.setValues(environmentValues(os.Environ()), "environment")...)
+
+	if _, err := NewBuilderFromConfig(config); err != nil {
+		problems = append(problems, err.(*ConfigError).Problems...)
+	}
+	if len(problems) > 0 {
+		return Config{}, &ConfigError{Problems: problems}
+	}
+	return config, nil
+}
+
+// NewBuilderFromConfig returns a ClientBuilder with the settings of config on top of
+// the defaults, which can still be changed before building the client.
+// The builder is validated like ClientBuilder.Validate does, and the files config
+// refers to are loaded. Every problem found is reported in a ConfigError.
+func NewBuilderFromConfig(config Config) (ClientBuilder, error) {
+	var problems []string
+
+	builder := NewBuilder().(*clientBuilder)
+	builder.applyConfig(config)
+
+	tlsConfig, err := config.TLS.load()
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if tlsConfig != nil {
+		builder.SetTLSConfig(tlsConfig)
+	}
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid proxy_url %q. %v", config.ProxyURL, err))
+		} else {
+			builder.SetProxy(proxyURL)
+		}
+	}
+
+	if err := builder.Validate(); err != nil {
+		problems = append(problems, err.(*ConfigError).Problems...)
+	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return builder, nil
+}
+
+// applyConfig sets the non-zero or loaded values of config, but the ones needing files
+// to be loaded.
+func (b *clientBuilder) applyConfig(config Config) {
+	durations := []struct {
+		key   string
+		value time.Duration
+		set   func(time.Duration) ClientBuilder
+	}{
+		{"connection_timeout", config.ConnectionTimeout, b.SetConnectionTimeout},
+		{"response_timeout", config.ResponseTimeout, b.SetResponseTimeout},
+		{"expect_continue_timeout", config.ExpectContinueTimeout, b.SetExpectContinueTimeout},
+		{"tls_handshake_timeout", config.TLSHandshakeTimeout, b.SetTLSHandshakeTimeout},
+		{"idle_conn_timeout", config.IdleConnTimeout, b.SetIdleConnTimeout},
+		{"dialer_keep_alive", config.DialerKeepAlive, b.SetDialerKeepAlive},
+		{"dialer_fallback_delay", config.DialerFallbackDelay, b.SetDialerFallbackDelay},
+	}
+	for _, d := range durations {
+		if d.value != 0 || config.loaded[d.key] {
+			d.set(d.value)
+		}
+	}
+
+	sizes := []struct {
+		key   string
+		value int
+		set   func(int) ClientBuilder
+	}{
+		{"max_idle_connections", config.MaxIdleConnections, b.SetMaxIdleConnections},
+		{"max_idle_connections_per_host", config.MaxIdleConnectionsPerHost, b.SetMaxIdleConnectionsPerHost},
+		{"max_connections_per_host", config.MaxConnectionsPerHost, b.SetMaxConnectionsPerHost},
+	}
+	for _, s := range sizes {
+		if s.value != 0 || config.loaded[s.key] {
+			s.set(s.value)
+		}
+	}
+
+	retries := b.retryPolicy
+	if config.MaxRetries != 0 || config.loaded["max_retries"] {
+		retries.MaxRetries = config.MaxRetries
+	}
+	if config.RetryBackoff != 0 || config.loaded["retry_backoff"] {
+		retries.Backoff = config.RetryBackoff
+	}
+	if config.RetryMaxBackoff != 0 || config.loaded["retry_max_backoff"] {
+		retries.MaxBackoff = config.RetryMaxBackoff
+	}
+	b.SetRetryPolicy(retries)
+
+	if config.DisableHTTP2 || config.loaded["disable_http2"] {
+		b.ForceAttemptHTTP2(!config.DisableHTTP2)
+	}
+	if config.BaseURL != "" {
+		b.SetBaseURL(config.BaseURL)
+	}
+	b.addHeaders(config.Headers)
+}
+
+// addHeaders sets headers on top of the ones already set.
+func (b *clientBuilder) addHeaders(headers map[string]string) {
+	if len(headers) == 0 {
+		return
+	}
+	merged := b.headers.Clone()
+	if merged == nil {
+		merged = make(http.Header)
+	}
+	for name, value := range headers {
+		merged.Set(name, value)
+	}
+	b.SetHeaders(merged)
+}
+
+// load returns the TLS configuration of the files, nil if none is set.
+func (f TLSFiles) load() (*tls.Config, error) {
+	if f == (TLSFiles{}) {
+		return nil, nil
+	}
+	config := &tls.Config{
+		ServerName:         f.ServerName,
+		InsecureSkipVerify: f.InsecureSkipVerify,
+	}
+
+	if f.CAFile != "" {
+		pem, err := ioutil.ReadFile(f.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tls.ca_file. %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in tls.ca_file %s", f.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if (f.CertFile == "") != (f.KeyFile == "") {
+		return nil, fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+	}
+	if f.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load tls.cert_file and tls.key_file. %v", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// setValues sets the values by key, remembering them as loaded, and returns the problems
+// found, prefixed with source.
+func (c *Config) setValues(values map[string]string, source string) []string {
+	var problems []string
+	for _, key := range sortedKeys(values) {
+		if err := c.set(key, values[key]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", source, err))
+			continue
+		}
+		if c.loaded == nil {
+			c.loaded = make(map[string]bool)
+		}
+		c.loaded[key] = true
+	}
+	return problems
+}
+
+// set sets the setting at key, like "response_timeout", "tls.ca_file" or "headers.Accept".
+func (c *Config) set(key string, value string) error {
+	if strings.HasPrefix(key, "headers.") {
+		if c.Headers == nil {
+			c.Headers = make(map[string]string)
+		}
+		c.Headers[strings.TrimPrefix(key, "headers.")] = value
+		return nil
+	}
+
+	field, ok := configField(reflect.ValueOf(c).Elem(), key)
+	if !ok {
+		return fmt.Errorf("unknown setting %s", key)
+	}
+
+	switch field.Interface().(type) {
+	case time.Duration:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q for %s", value, key)
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q for %s", value, key)
+		}
+		field.SetInt(int64(number))
+	case reflect.Bool:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q for %s", value, key)
+		}
+		field.SetBool(enabled)
+	default:
+		return fmt.Errorf("%s can't be set to a single value", key)
+	}
+	return nil
+}
+
+// configField returns the field of the struct v at key, following nested structs.
+func configField(v reflect.Value, key string) (reflect.Value, bool) {
+	name, rest := key, ""
+	if i := strings.IndexByte(key, '.'); i >= 0 {
+		name, rest = key[:i], key[i+1:]
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("config") != name {
+			continue
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if rest == "" {
+				return reflect.Value{}, false
+			}
+			return configField(field, rest)
+		}
+		return field, rest == ""
+	}
+	return reflect.Value{}, false
+}
+
+// configKeys returns the keys of the settings of the struct type t, but headers.
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := prefix + field.Tag.Get("config")
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			keys = append(keys, configKeys(field.Type, key+".")...)
+		case reflect.Map:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file. %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseJSONConfig(data)
+	case ".yaml", ".yml":
+		return parseYAMLConfig(data)
+	}
+	return nil, fmt.Errorf("unsupported config file %s. It must be .json, .yaml or .yml", path)
+}
+
+// parseJSONConfig returns the values of a JSON object, with keys of nested
+// objects joined by dots.
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("unable to parse JSON config. %v", err)
+	}
+
+	values := make(map[string]string)
+	if err := flattenJSONConfig(object, "", values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func flattenJSONConfig(object map[string]interface{}, prefix string, values map[string]string) error {
+	for key, value := range object {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flattenJSONConfig(v, prefix+key+".", values); err != nil {
+				return err
+			}
+		case string:
+			values[prefix+key] = v
+		case json.Number:
+			values[prefix+key] = v.String()
+		case bool:
+			values[prefix+key] = strconv.FormatBool(v)
+		case nil:
+		default:
+			return fmt.Errorf("unable to parse JSON config. Unsupported value for %s%s", prefix, key)
+		}
+	}
+	return nil
+}
+
+// parseYAMLConfig returns the values of a YAML subset made of "key: value" lines,
+// with keys nested under a "key:" line by indentation joined by dots.
+// Values may be quoted, and lines starting with # are comments.
+func parseYAMLConfig(data []byte) (map[string]string, error) {
+	type section struct {
+		indent int
+		prefix string
+	}
+	var sections []section
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("unable to parse YAML config. Line %d is indented with tabs", number)
+		}
+		indent := len(line) - len(trimmed)
+
+		i := strings.IndexByte(trimmed, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("unable to parse YAML config. Line %d is not key: value", number)
+		}
+		key, value := strings.TrimSpace(trimmed[:i]), strings.TrimSpace(trimmed[i+1:])
+
+		for len(sections) > 0 && indent <= sections[len(sections)-1].indent {
+			sections = sections[:len(sections)-1]
+		}
+		prefix := ""
+		if len(sections) > 0 {
+			prefix = sections[len(sections)-1].prefix
+		}
+
+		if value == "" {
+			sections = append(sections, section{indent: indent, prefix: prefix + key + "."})
+			continue
+		}
+		unquoted, err := unquoteYAMLValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse YAML config. Line %d: %v", number, err)
+		}
+		values[prefix+key] = unquoted
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to parse YAML config. %v", err)
+	}
+	return values, nil
+}
+
+func unquoteYAMLValue(value string) (string, error) {
+	switch value[0] {
+	case '"':
+		return strconv.Unquote(value)
+	case '\'':
+		if len(value) < 2 || value[len(value)-1] != '\'' {
+			return "", fmt.Errorf("unterminated quoted value %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	// Trailing comment
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
+
+// environmentValues returns the settings in environ, a list of "NAME=value",
+// named after EnvPrefix.
+func environmentValues(environ []string) map[string]string {
+	keysByName := make(map[string]string)
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		keysByName[EnvPrefix+strings.ToUpper(strings.ReplaceAll(key, ".", "_"))] = key
+	}
+
+	values := make(map[string]string)
+	for _, variable := range environ {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], EnvPrefix) {
+			continue
+		}
+		name, value := parts[0], parts[1]
+
+		if header := strings.TrimPrefix(name, EnvPrefix+"HEADERS_"); header != name {
+			header = textproto.CanonicalMIMEHeaderKey(strings.ReplaceAll(header, "_", "-"))
+			values["headers."+header] = value
+			continue
+		}
+		// Other variables sharing the prefix aren't settings, so they're ignored
+		if key, ok := keysByName[name]; ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r >= 0x7f || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,338 @@
+package gohttpclient
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigYAMLAndEnvironment(t *testing.T) {
+
+	// Initialization
+	path := writeConfigFile(t, "client.yaml", `
+# Client settings
+connection_timeout: 2s
+response_timeout: 10s
+max_idle_connections_per_host: 8
+proxy_url: "http://proxy.internal:3128"
+headers:
+  User-Agent: 'sync ''v2'''
+  Accept: application/json # trailing comment
+tls:
+  server_name: api.internal
+`)
+	os.Setenv("HTTPCLIENT_RESPONSE_TIMEOUT", "3s")
+	os.Setenv("HTTPCLIENT_HEADERS_X_API_KEY", "secret")
+	os.Setenv("HTTPCLIENT_TLS_INSECURE_SKIP_VERIFY", "true")
+	os.Setenv("HTTPCLIENT_VERSION", "1.2.0")
+	defer os.Unsetenv("HTTPCLIENT_VERSION")
+	defer os.Unsetenv("HTTPCLIENT_RESPONSE_TIMEOUT")
+	defer os.Unsetenv("HTTPCLIENT_HEADERS_X_API_KEY")
+	defer os.Unsetenv("HTTPCLIENT_TLS_INSECURE_SKIP_VERIFY")
+
+	// Execution
+	config, err := LoadConfig(path)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if config.ConnectionTimeout != 2*time.Second || config.ResponseTimeout != 3*time.Second {
+		t.Errorf("Invalid timeouts %v and %v", config.ConnectionTimeout, config.ResponseTimeout)
+	}
+	if config.MaxIdleConnectionsPerHost != 8 || config.ProxyURL != "http://proxy.internal:3128" {
+		t.Errorf("Invalid config %+v", config)
+	}
+	if config.Headers["User-Agent"] != "sync 'v2'" || config.Headers["Accept"] != "application/json" || config.Headers["X-Api-Key"] != "secret" {
+		t.Errorf("Invalid headers %v", config.Headers)
+	}
+	if config.TLS.ServerName != "api.internal" || !config.TLS.InsecureSkipVerify {
+		t.Errorf("Invalid TLS config %+v", config.TLS)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+
+	// Initialization
+	path := writeConfigFile(t, "client.json", `{
+		"response_timeout": "soon",
+		"max_connections_per_host": -1,
+		"retries": 3,
+		"tls": {"cert_file": "client.pem"}
+	}`)
+
+	// Execution
+	_, err := LoadConfig(path)
+
+	// Validation
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Config error expected, got %v", err)
+	}
+	message := err.Error()
+	for _, expected := range []string{`invalid duration "soon"`, "unknown setting retries", "max connections per host must not be negative", "tls.cert_file and tls.key_file"} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Problem %q expected in %q", expected, message)
+		}
+	}
+	if len(configErr.Problems) != 4 {
+		t.Errorf("Every problem should be reported, got %q", configErr.Problems)
+	}
+}
+
+func TestLoadConfigValidatesBuilder(t *testing.T) {
+
+	// Initialization
+	path := writeConfigFile(t, "client.yaml", `
+max_connections_per_host: 10
+proxy_url: ftp://proxy.internal
+`)
+
+	// Execution
+	_, err := LoadConfig(path)
+
+	// Validation
+	if err == nil || !strings.Contains(err.Error(), "max idle connections per host 20 is larger than max connections per host 10") {
+		t.Errorf("Conflict with the default max idle connections per host should be reported, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "proxy URL scheme must be http, https or socks5") {
+		t.Errorf("Unsupported proxy scheme should be reported, got %v", err)
+	}
+}
+
+func TestLoadConfigRetries(t *testing.T) {
+
+	// Initialization
+	path := writeConfigFile(t, "client.yaml", `
+max_retries: 3
+retry_backoff: 50ms
+`)
+	os.Setenv("HTTPCLIENT_RETRY_MAX_BACKOFF", "2s")
+	defer os.Unsetenv("HTTPCLIENT_RETRY_MAX_BACKOFF")
+
+	// Execution
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	builder, err := NewBuilderFromConfig(config)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error creating builder: %v", err)
+	}
+	expected := RetryPolicy{MaxRetries: 3, Backoff: 50 * time.Millisecond, MaxBackoff: 2 * time.Second}
+	if policy := builder.(*clientBuilder).retryPolicy; policy != expected {
+		t.Errorf("Invalid retry policy %+v", policy)
+	}
+}
+
+func TestLoadConfigZeroValues(t *testing.T) {
+
+	// Initialization
+	os.Setenv("HTTPCLIENT_MAX_CONNECTIONS_PER_HOST", "0")
+	os.Setenv("HTTPCLIENT_DIALER_KEEP_ALIVE", "0s")
+	defer os.Unsetenv("HTTPCLIENT_MAX_CONNECTIONS_PER_HOST")
+	defer os.Unsetenv("HTTPCLIENT_DIALER_KEEP_ALIVE")
+
+	// Execution
+	config, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	builder, err := NewBuilderFromConfig(config)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error creating builder: %v", err)
+	}
+	b := builder.(*clientBuilder)
+	if b.maxConnsPerHost != 0 || b.keepAliveTime != 0 {
+		t.Errorf("Loaded zero values should override the defaults, got %d and %v", b.maxConnsPerHost, b.keepAliveTime)
+	}
+	if b.maxIdleConns != DefaultConfig().MaxIdleConnections {
+		t.Errorf("Settings not loaded should keep their defaults, got %d", b.maxIdleConns)
+	}
+}
+
+func TestNewBuilderFromConfigProxy(t *testing.T) {
+
+	// Initialization
+	requested := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.URL.String() + " " + r.Header.Get("X-Team")
+	}))
+	defer proxy.Close()
+
+	config := Config{
+		ResponseTimeout: 5 * time.Second,
+		ProxyURL:        proxy.URL,
+		Headers:         map[string]string{"X-Team": "sync"},
+	}
+
+	// Execution
+	builder, err := NewBuilderFromConfig(config)
+	if err != nil {
+		t.Fatalf("Error creating builder: %v", err)
+	}
+	response, err := builder.Build().GET("http://service.invalid/items", nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	response.Body.Close()
+	if got := <-requested; got != "http://service.invalid/items sync" {
+		t.Errorf("Request should go through the proxy, got %q", got)
+	}
+	if builder.(*clientBuilder).responseTimeOut != 5*time.Second {
+		t.Errorf("Invalid response timeout %v", builder.(*clientBuilder).responseTimeOut)
+	}
+}
+
+func TestNewBuilderFromConfigCAFile(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// The handshake rejected without the CA file is expected
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writeConfigFile(t, "ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+
+	// Execution
+	builder, err := NewBuilderFromConfig(Config{TLS: TLSFiles{CAFile: caFile}})
+	if err != nil {
+		t.Fatalf("Error creating builder: %v", err)
+	}
+	response, err := builder.Build().GET(server.URL, nil)
+	_, defaultErr := NewBuilder().Build().GET(server.URL, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Server should be trusted with the CA file, got %v", err)
+	}
+	response.Body.Close()
+	if defaultErr == nil {
+		t.Error("Server should not be trusted without the CA file")
+	}
+}
+
+func TestNewBuilderUsesDefaults(t *testing.T) {
+
+	// Execution
+	b := NewBuilder().(*clientBuilder)
+
+	// Validation
+	got := Config{
+		ConnectionTimeout:         b.connectionTimeout,
+		ResponseTimeout:           b.responseTimeOut,
+		ExpectContinueTimeout:     b.expectContinueTimeout,
+		TLSHandshakeTimeout:       b.tlsHandshakeTimeout,
+		IdleConnTimeout:           b.idleConnTimeout,
+		MaxIdleConnections:        b.maxIdleConns,
+		MaxIdleConnectionsPerHost: b.maxIdleConnsPerHost,
+		MaxConnectionsPerHost:     b.maxConnsPerHost,
+		DialerKeepAlive:           b.keepAliveTime,
+		DialerFallbackDelay:       b.fallbackDelay,
+		DisableHTTP2:              !b.forceAttemptHTTP2Enabled,
+	}
+	if defaults := DefaultConfig(); !reflect.DeepEqual(got, defaults) {
+		t.Errorf("Builder settings %+v differ from defaults %+v", got, defaults)
+	}
+}
+
+func TestDefaultsMatchDocumentation(t *testing.T) {
+
+	// Initialization
+	defaults := DefaultConfig()
+	expected := map[string]string{
+		"SetConnectionTimeout":         describeDefault(defaults.ConnectionTimeout),
+		"SetResponseTimeout":           describeDefault(defaults.ResponseTimeout),
+		"SetExpectContinueTimeout":     describeDefault(defaults.ExpectContinueTimeout),
+		"SetTLSHandshakeTimeout":       describeDefault(defaults.TLSHandshakeTimeout),
+		"SetIdleConnTimeout":           describeDefault(defaults.IdleConnTimeout),
+		"SetMaxIdleConnections":        describeDefault(defaults.MaxIdleConnections),
+		"SetMaxIdleConnectionsPerHost": describeDefault(defaults.MaxIdleConnectionsPerHost),
+		"SetMaxConnectionsPerHost":     describeDefault(defaults.MaxConnectionsPerHost),
+		"SetDialerKeepAlive":           describeDefault(defaults.DialerKeepAlive),
+		"SetDialerFallbackDelay":       describeDefault(defaults.DialerFallbackDelay),
+		"ForceAttemptHTTP2":            describeDefault(!defaults.DisableHTTP2),
+	}
+
+	// Execution
+	documented := documentedDefaults(t, "client_builder.go", "ClientBuilder")
+
+	// Validation
+	for method, value := range expected {
+		if documented[method] != value {
+			t.Errorf("%s documents default %q, DefaultConfig has %q", method, documented[method], value)
+		}
+	}
+}
+
+// documentedDefaults returns the "Default is ..." value documented on each method of
+// the interface named name in the file at path.
+func documentedDefaults(t *testing.T, path string, name string) map[string]string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Error parsing %s: %v", path, err)
+	}
+
+	defaultPattern := regexp.MustCompile(`Default is (.+?)\.(\s|$)`)
+	documented := make(map[string]string)
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok || spec.Name.Name != name {
+			return true
+		}
+		for _, method := range spec.Type.(*ast.InterfaceType).Methods.List {
+			if match := defaultPattern.FindStringSubmatch(method.Doc.Text()); match != nil {
+				documented[method.Names[0].Name] = match[1]
+			}
+		}
+		return false
+	})
+	return documented
+}
+
+// describeDefault writes value the way defaults are documented, like "30 seconds".
+func describeDefault(value interface{}) string {
+	switch v := value.(type) {
+	case time.Duration:
+		switch {
+		case v == time.Second:
+			return "1 second"
+		case v%time.Second == 0:
+			return fmt.Sprintf("%d seconds", v/time.Second)
+		default:
+			return fmt.Sprintf("%d milliseconds", v/time.Millisecond)
+		}
+	}
+	return fmt.Sprint(value)
+}
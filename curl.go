@@ -0,0 +1,91 @@
+package gohttpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// CurlCommand returns a copy-pasteable curl command reproducing the request,
+// with the secrets listed by policy redacted.
+// The request body is read through GetBody, so it works with the requests of
+// the responses returned by the client, as in CurlCommand(response.Request, policy).
+func CurlCommand(request *http.Request, policy RedactionPolicy) (string, error) {
+	parts := []string{"curl"}
+
+	if request.Method != "" && request.Method != http.MethodGet {
+		parts = append(parts, "-X", shellQuote(request.Method))
+	}
+	parts = append(parts, shellQuote(policy.RedactURL(request.URL)))
+
+	headers := policy.RedactHeaders(request.Header)
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range headers[key] {
+			parts = append(parts, "-H", shellQuote(key+": "+value))
+		}
+	}
+	if request.Host != "" && request.Host != request.URL.Host {
+		parts = append(parts, "-H", shellQuote("Host: "+request.Host))
+	}
+
+	if request.GetBody != nil && request.ContentLength != 0 {
+		body, err := request.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+
+		bodyBytes, err := ioutil.ReadAll(body)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "--data-binary", shellQuote(string(policy.RedactJSON(bodyBytes))))
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+// shellQuote quotes a value for POSIX shells.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// CurlHandler receives the curl command reproducing a request sent by the client.
+type CurlHandler func(command string)
+
+type curlHandlerKey struct{}
+
+// WithCurlHandler returns a copy of ctx making the client call handler
+// with the curl command of every request sent with it, redacted with the client policy.
+func WithCurlHandler(ctx context.Context, handler CurlHandler) context.Context {
+	return context.WithValue(ctx, curlHandlerKey{}, handler)
+}
+
+// curlCommand returns the curl command of the request if it's requested through its
+// context, or logged because the request failed. It's empty otherwise.
+func (c *client) curlCommand(request *http.Request, failed bool) string {
+	handler, _ := request.Context().Value(curlHandlerKey{}).(CurlHandler)
+	logIt := failed && c.builder.curlOnFailure && c.builder.logger != nil && c.builder.logLevel > LogOff
+	if handler == nil && !logIt {
+		return ""
+	}
+
+	command, err := CurlCommand(request, c.builder.redactionPolicy)
+	if err != nil {
+		return ""
+	}
+	if handler != nil {
+		handler(command)
+	}
+	if !logIt {
+		return ""
+	}
+	return command
+}
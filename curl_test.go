@@ -0,0 +1,102 @@
+package gohttpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCurlCommand(t *testing.T) {
+
+	// Initialization
+	request, _ := http.NewRequest(http.MethodPost, "https://api.example.com/items?token=abc&page=1",
+		strings.NewReader(`{"name":"it's","password":"secret"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer secret")
+
+	policy := DefaultRedactionPolicy()
+	policy.QueryParams = []string{"token"}
+	policy.JSONFields = []string{"password"}
+
+	// Execution
+	command, err := CurlCommand(request, policy)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Cannot build curl command. %v", err)
+	}
+	expected := `curl -X 'POST' 'https://api.example.com/items?token=%5BREDACTED%5D&page=1'` +
+		` -H 'Authorization: [REDACTED]' -H 'Content-Type: application/json'` +
+		` --data-binary '{"name":"it'\''s","password":"[REDACTED]"}'`
+	if command != expected {
+		t.Errorf("Invalid curl command:\n%s\nexpected:\n%s", command, expected)
+	}
+}
+
+func TestCurlCommandFromClient(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	var entries []LogEntry
+	c := NewBuilder().
+		SetLogger(RequestLoggerFunc(func(entry LogEntry) { entries = append(entries, entry) }), LogBasic).
+		LogCurlOnFailure(true).
+		Build()
+
+	var handled string
+	ctx := WithCurlHandler(context.Background(), func(command string) { handled = command })
+
+	// Execution
+	resp, err := c.Do(ctx, http.MethodPut, server.URL+"/items/1", nil, map[string]int{"count": 2})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing PUT: %v", err)
+	}
+	resp.Body.Close()
+
+	expected := `curl -X 'PUT' '` + server.URL + `/items/1' -H 'Accept: application/json'` +
+		` -H 'Content-Type: application/json' --data-binary '{"count":2}'`
+	if handled != expected {
+		t.Errorf("Invalid curl command:\n%s\nexpected:\n%s", handled, expected)
+	}
+	if len(entries) != 1 || entries[0].Curl != expected {
+		t.Errorf("Curl command was not logged for the failed request: %+v", entries)
+	}
+
+	fromResponse, err := CurlCommand(resp.Request, DefaultRedactionPolicy())
+	if err != nil || fromResponse != expected {
+		t.Errorf("Invalid curl command from response:\n%s", fromResponse)
+	}
+}
+
+func TestCurlNotLoggedOnSuccess(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var entries []LogEntry
+	c := NewBuilder().
+		SetLogger(RequestLoggerFunc(func(entry LogEntry) { entries = append(entries, entry) }), LogBasic).
+		LogCurlOnFailure(true).
+		Build()
+
+	// Execution
+	resp, err := c.GET(server.URL, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	if len(entries) != 1 || entries[0].Curl != "" {
+		t.Errorf("Curl command should not be logged: %+v", entries)
+	}
+}
@@ -0,0 +1,280 @@
+package gohttpclient
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestAuth keeps the last Digest challenge received by a client so following
+// requests are authorized right away, without a new challenge round-trip.
+type digestAuth struct {
+	username string
+	password string
+
+	mutex     sync.Mutex
+	challenge *digestChallenge
+	nc        uint32
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+}
+
+func newDigestAuth(username, password string) *digestAuth {
+	return &digestAuth{
+		username: username,
+		password: password,
+	}
+}
+
+// sendWithDigestAuth sends the request authorizing it with the cached challenge, if any.
+// When the server answers 401 with a Digest challenge the request is sent again,
+// only once, authorized with the new challenge.
+func (c *client) sendWithDigestAuth(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+
+	request, err := c.newRequest(ctx, method, url, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	authorized, err := c.digestAuth.authorize(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.send(request)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	challenge := findDigestChallenge(response.Header.Values("WWW-Authenticate"))
+	if challenge == nil {
+		return response, nil
+	}
+	// Credentials were rejected with a valid nonce, so there's no point in trying again.
+	if authorized && !challenge.stale {
+		return response, nil
+	}
+
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+
+	c.digestAuth.setChallenge(challenge)
+
+	request, err = c.newRequest(withAttempt(ctx, attemptFromContext(ctx)+1), method, url, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.digestAuth.authorize(request); err != nil {
+		return nil, err
+	}
+	return c.send(request)
+}
+
+func (d *digestAuth) setChallenge(challenge *digestChallenge) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.challenge = challenge
+	d.nc = 0
+}
+
+// authorize sets the Authorization header if a challenge was received before.
+// Every call increments the nonce count.
+func (d *digestAuth) authorize(request *http.Request) (bool, error) {
+	d.mutex.Lock()
+	challenge := d.challenge
+	if challenge == nil {
+		d.mutex.Unlock()
+		return false, nil
+	}
+	d.nc++
+	nc := d.nc
+	d.mutex.Unlock()
+
+	cnonce, err := newCnonce()
+	if err != nil {
+		return false, fmt.Errorf("unable to create digest cnonce. %v", err)
+	}
+
+	request.Header.Set("Authorization", d.authorization(challenge, request.Method, request.URL.RequestURI(), nc, cnonce))
+	return true, nil
+}
+
+func (d *digestAuth) authorization(challenge *digestChallenge, method, uri string, nc uint32, cnonce string) string {
+	ncValue := fmt.Sprintf("%08x", nc)
+
+	params := []string{
+		fmt.Sprintf("username=%q", d.username),
+		fmt.Sprintf("realm=%q", challenge.realm),
+		fmt.Sprintf("nonce=%q", challenge.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("algorithm=%s", challenge.algorithm),
+		fmt.Sprintf("response=%q", d.response(challenge, method, uri, ncValue, cnonce)),
+	}
+	if challenge.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", challenge.opaque))
+	}
+	if challenge.qop != "" {
+		params = append(params,
+			fmt.Sprintf("qop=%s", challenge.qop),
+			fmt.Sprintf("nc=%s", ncValue),
+			fmt.Sprintf("cnonce=%q", cnonce),
+		)
+	}
+	return "Digest " + strings.Join(params, ", ")
+}
+
+// response computes the request digest as defined by RFC 7616, section 3.4.1.
+func (d *digestAuth) response(challenge *digestChallenge, method, uri, nc, cnonce string) string {
+	newHash := digestHashFunc(challenge.algorithm)
+	h := func(data string) string {
+		hasher := newHash()
+		hasher.Write([]byte(data))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	ha1 := h(d.username + ":" + challenge.realm + ":" + d.password)
+	if strings.HasSuffix(strings.ToUpper(challenge.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	if challenge.qop == "" {
+		return h(ha1 + ":" + challenge.nonce + ":" + ha2)
+	}
+	return h(ha1 + ":" + challenge.nonce + ":" + nc + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+}
+
+func digestHashFunc(algorithm string) func() hash.Hash {
+	switch baseDigestAlgorithm(algorithm) {
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	default:
+		return md5.New
+	}
+}
+
+// findDigestChallenge returns the strongest supported Digest challenge
+// among the WWW-Authenticate header values, or nil if there's none.
+func findDigestChallenge(headerValues []string) *digestChallenge {
+	strength := map[string]int{"MD5": 1, "SHA-256": 2, "SHA-512-256": 3}
+
+	var best *digestChallenge
+	for _, value := range headerValues {
+		value = strings.TrimSpace(value)
+		if len(value) < 7 || !strings.EqualFold(value[:7], "Digest ") {
+			continue
+		}
+		params := parseAuthParams(value[7:])
+
+		challenge := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			stale:     strings.EqualFold(params["stale"], "true"),
+		}
+		if challenge.nonce == "" {
+			continue
+		}
+		if challenge.algorithm == "" {
+			challenge.algorithm = "MD5"
+		}
+		if _, ok := strength[baseDigestAlgorithm(challenge.algorithm)]; !ok {
+			continue
+		}
+		if qop, ok := params["qop"]; ok {
+			// Only qop=auth is supported, auth-int would require hashing the body
+			for _, option := range strings.Split(qop, ",") {
+				if strings.TrimSpace(option) == "auth" {
+					challenge.qop = "auth"
+				}
+			}
+			if challenge.qop == "" {
+				continue
+			}
+		}
+
+		if best == nil || strength[baseDigestAlgorithm(challenge.algorithm)] > strength[baseDigestAlgorithm(best.algorithm)] {
+			best = challenge
+		}
+	}
+	return best
+}
+
+func baseDigestAlgorithm(algorithm string) string {
+	return strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")
+}
+
+// parseAuthParams parses a comma separated list of auth-params, where values
+// may be tokens or quoted strings. Keys are lowercased.
+func parseAuthParams(value string) map[string]string {
+	params := make(map[string]string)
+
+	for len(value) > 0 {
+		value = strings.TrimLeft(value, " \t,")
+		eq := strings.IndexByte(value, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(value[:eq]))
+		value = strings.TrimLeft(value[eq+1:], " \t")
+
+		var paramValue string
+		if strings.HasPrefix(value, `"`) {
+			var builder strings.Builder
+			i := 1
+			for ; i < len(value); i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+					builder.WriteByte(value[i])
+					continue
+				}
+				if value[i] == '"' {
+					break
+				}
+				builder.WriteByte(value[i])
+			}
+			paramValue = builder.String()
+			if i < len(value) {
+				i++
+			}
+			value = value[i:]
+		} else {
+			end := strings.IndexByte(value, ',')
+			if end < 0 {
+				end = len(value)
+			}
+			paramValue = strings.TrimSpace(value[:end])
+			value = value[end:]
+		}
+		params[key] = paramValue
+	}
+	return params
+}
+
+func newCnonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,196 @@
+package gohttpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDigestResponseRFC7616(t *testing.T) {
+
+	// Initialization
+	// Example from RFC 7616, section 3.9.1
+	d := newDigestAuth("Mufasa", "Circle of Life")
+	challenge := &digestChallenge{
+		realm:  "http-auth@example.org",
+		nonce:  "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+		opaque: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+		qop:    "auth",
+	}
+	cnonce := "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+
+	// Execution
+	challenge.algorithm = "MD5"
+	md5Response := d.response(challenge, http.MethodGet, "/dir/index.html", "00000001", cnonce)
+	challenge.algorithm = "SHA-256"
+	sha256Response := d.response(challenge, http.MethodGet, "/dir/index.html", "00000001", cnonce)
+
+	// Validation
+	if md5Response != "8ca523f5e9506fed4657c9700eebdbec" {
+		t.Errorf("Invalid MD5 response: %s", md5Response)
+	}
+	if sha256Response != "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1" {
+		t.Errorf("Invalid SHA-256 response: %s", sha256Response)
+	}
+}
+
+func TestFindDigestChallenge(t *testing.T) {
+
+	// Execution
+	challenge := findDigestChallenge([]string{
+		`Basic realm="api"`,
+		`Digest realm="api", qop="auth, auth-int", algorithm=MD5, nonce="n1", opaque="o"`,
+		`Digest realm="api", qop="auth", algorithm=SHA-256, nonce="n2", stale=TRUE`,
+	})
+
+	// Validation
+	if challenge == nil {
+		t.Fatal("Digest challenge was not found")
+	}
+	if challenge.algorithm != "SHA-256" || challenge.nonce != "n2" {
+		t.Errorf("Strongest challenge was not chosen: %+v", challenge)
+	}
+	if challenge.qop != "auth" || !challenge.stale {
+		t.Errorf("Invalid challenge params: %+v", challenge)
+	}
+}
+
+// digestServer is a minimal RFC 7616 server accepting a single user.
+type digestServer struct {
+	mutex     sync.Mutex
+	nonce     string
+	algorithm string
+	requests  int
+	ncSeen    []string
+}
+
+func (s *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests++
+
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Digest ") {
+		s.challenge(w, false)
+		return
+	}
+	params := parseAuthParams(authorization[len("Digest "):])
+	if params["nonce"] != s.nonce {
+		s.challenge(w, true)
+		return
+	}
+
+	d := newDigestAuth("user", "pass")
+	challenge := &digestChallenge{realm: "test", nonce: s.nonce, algorithm: s.algorithm, qop: "auth"}
+	expected := d.response(challenge, r.Method, params["uri"], params["nc"], params["cnonce"])
+	if params["response"] != expected || params["username"] != "user" {
+		s.challenge(w, false)
+		return
+	}
+
+	s.ncSeen = append(s.ncSeen, params["nc"])
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *digestServer) challenge(w http.ResponseWriter, stale bool) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="test", qop="auth", algorithm=%s, nonce=%q, stale=%t`,
+		s.algorithm, s.nonce, stale))
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func TestDigestAuthCachesNonce(t *testing.T) {
+
+	for _, algorithm := range []string{"MD5", "SHA-256"} {
+		t.Run(algorithm, func(t *testing.T) {
+
+			// Initialization
+			handler := &digestServer{nonce: "nonce-1", algorithm: algorithm}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			c := NewBuilder().SetDigestAuth("user", "pass").Build()
+
+			// Execution
+			for i := 0; i < 2; i++ {
+				resp, err := c.POST(server.URL+"/resource?id=1", nil, map[string]int{"value": i})
+				if err != nil {
+					t.Fatalf("Error executing POST: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("Invalid status code %d", resp.StatusCode)
+				}
+			}
+
+			// Validation
+			if handler.requests != 3 {
+				t.Errorf("Only the first request should be challenged, the server got %d requests", handler.requests)
+			}
+			if strings.Join(handler.ncSeen, ",") != "00000001,00000002" {
+				t.Errorf("Invalid nonce counts: %v", handler.ncSeen)
+			}
+		})
+	}
+}
+
+func TestDigestAuthStaleNonce(t *testing.T) {
+
+	// Initialization
+	handler := &digestServer{nonce: "nonce-1", algorithm: "SHA-256"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := NewBuilder().SetDigestAuth("user", "pass").Build()
+	resp, err := c.GET(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+
+	handler.mutex.Lock()
+	handler.nonce = "nonce-2"
+	handler.mutex.Unlock()
+
+	// Execution
+	resp, err = c.GET(server.URL, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Stale nonce was not renewed, status code %d", resp.StatusCode)
+	}
+	if strings.Join(handler.ncSeen, ",") != "00000001,00000001" {
+		t.Errorf("Nonce count was not reset for the new nonce: %v", handler.ncSeen)
+	}
+}
+
+func TestDigestAuthInvalidCredentials(t *testing.T) {
+
+	// Initialization
+	handler := &digestServer{nonce: "nonce-1", algorithm: "MD5"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := NewBuilder().SetDigestAuth("user", "wrong").Build()
+
+	// Execution
+	resp, err := c.GET(server.URL, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Invalid status code %d", resp.StatusCode)
+	}
+	if handler.requests != 2 {
+		t.Errorf("Rejected credentials should not be retried, the server got %d requests", handler.requests)
+	}
+}
@@ -0,0 +1,153 @@
+package gohttpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// Bounds of the time endpoints from a source are used before being refreshed
+	minDiscoveryRefresh time.Duration = time.Second
+	maxDiscoveryRefresh time.Duration = time.Hour
+
+	// Time to wait before looking up endpoints again when a lookup failed
+	discoveryRetryDelay time.Duration = 5 * time.Second
+
+	discoveryTimeout time.Duration = 5 * time.Second
+)
+
+// Endpoint is a base URL requests with a relative URL can be sent to.
+type Endpoint struct {
+	BaseURL string
+
+	// Priority of the endpoint. Only the endpoints with the lowest value are used
+	// while any of them is available.
+	Priority uint16
+
+	// Weight of the endpoint among the ones with the same priority. Requests are
+	// balanced proportionally to it. Endpoints with zero weight are only used when
+	// none with the same priority has a positive weight, and then equally.
+	Weight uint16
+}
+
+// EndpointSource provides the endpoints of a client, like a service discovery system.
+type EndpointSource interface {
+	// Endpoints returns the current endpoints, and for how long they can be used
+	// before asking for them again.
+	Endpoints(ctx context.Context) ([]Endpoint, time.Duration, error)
+}
+
+// SRVSource is an EndpointSource looking up the DNS SRV records of a service,
+// like _api._tcp.example.com. Every record makes an endpoint with its target, port,
+// priority and weight. Endpoints are refreshed when the records TTL expires.
+type SRVSource struct {
+	// Service, Proto and Name of the records looked up, like "api", "tcp" and "example.com".
+	Service string
+	Proto   string
+	Name    string
+
+	// Scheme of the endpoints base URL. Default is "https".
+	Scheme string
+
+	// Path of the endpoints base URL, like "/api". Default is none.
+	Path string
+
+	// Resolver to look up the records with. Default is a DNSResolver using the
+	// system name servers.
+	Resolver SRVResolver
+}
+
+// SRVRecord is a DNS SRV record.
+type SRVRecord struct {
+	Target   string
+	Port     uint16
+	Priority uint16
+	Weight   uint16
+	TTL      time.Duration
+}
+
+// SRVResolver looks up the SRV records with a name, like _api._tcp.example.com.
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, name string) ([]SRVRecord, error)
+}
+
+func (s *SRVSource) Endpoints(ctx context.Context) ([]Endpoint, time.Duration, error) {
+	resolver := s.Resolver
+	if resolver == nil {
+		resolver = SystemDNSResolver()
+	}
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	name := fmt.Sprintf("_%s._%s.%s", s.Service, s.Proto, s.Name)
+	records, err := resolver.LookupSRV(ctx, name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to lookup SRV records of %s. %v", name, err)
+	}
+	// A single "." target means the service is not available at this domain
+	if len(records) == 0 || (len(records) == 1 && records[0].Target == ".") {
+		return nil, 0, fmt.Errorf("no SRV records found for %s", name)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Priority < records[j].Priority })
+
+	endpoints := make([]Endpoint, len(records))
+	ttl := maxDiscoveryRefresh
+	for i, record := range records {
+		host := net.JoinHostPort(trimDot(record.Target), strconv.Itoa(int(record.Port)))
+		endpoints[i] = Endpoint{
+			BaseURL:  scheme + "://" + host + s.Path,
+			Priority: record.Priority,
+			Weight:   record.Weight,
+		}
+		if record.TTL < ttl {
+			ttl = record.TTL
+		}
+	}
+	return endpoints, ttl, nil
+}
+
+func trimDot(name string) string {
+	if len(name) > 1 && name[len(name)-1] == '.' {
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// startDiscovery keeps the endpoints of the client up to date with the source,
+// until the client is closed. The endpoints in use are kept when a lookup fails.
+func (c *client) startDiscovery(source EndpointSource) {
+	c.runInBackground(func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+			endpoints, ttl, err := source.Endpoints(ctx)
+			cancel()
+
+			refresh := discoveryRetryDelay
+			if err == nil && len(endpoints) > 0 {
+				c.endpointPool.setEndpoints(endpoints)
+				refresh = ttl
+			} else {
+				c.endpointPool.setLookupError(err)
+			}
+			if refresh < minDiscoveryRefresh {
+				refresh = minDiscoveryRefresh
+			}
+			if refresh > maxDiscoveryRefresh {
+				refresh = maxDiscoveryRefresh
+			}
+
+			select {
+			case <-time.After(refresh):
+			case <-c.stop:
+				return
+			}
+		}
+	})
+}
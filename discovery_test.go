@@ -0,0 +1,307 @@
+package gohttpclient
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDNSServer answers SRV and A queries over UDP and TCP with the records set for
+// every name.
+type fakeDNSServer struct {
+	conn     net.PacketConn
+	listener net.Listener
+
+	mutex    sync.Mutex
+	records  map[string][]SRVRecord
+	ips      map[string]net.IP
+	queries  int
+	truncate bool
+}
+
+func newFakeDNSServer(t *testing.T) *fakeDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot start DNS server. %v", err)
+	}
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		conn.Close()
+		t.Fatalf("Cannot start DNS server. %v", err)
+	}
+	server := &fakeDNSServer{conn: conn, listener: listener, records: make(map[string][]SRVRecord), ips: make(map[string]net.IP)}
+	go server.serve()
+	go server.serveTCP()
+	return server
+}
+
+func (s *fakeDNSServer) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *fakeDNSServer) Close() {
+	s.conn.Close()
+	s.listener.Close()
+}
+
+// TruncateUDP makes UDP responses truncated, without answers, like the ones too large
+// for a UDP message.
+func (s *fakeDNSServer) TruncateUDP(truncate bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.truncate = truncate
+}
+
+func (s *fakeDNSServer) Set(name string, records ...SRVRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records[name] = records
+}
+
+func (s *fakeDNSServer) SetIP(name string, ip net.IP) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ips[name] = ip
+}
+
+func (s *fakeDNSServer) Queries() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.queries
+}
+
+func (s *fakeDNSServer) serve() {
+	buffer := make([]byte, maxDNSMessageSize)
+	for {
+		n, addr, err := s.conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		response := s.answer(buffer[:n])
+		s.mutex.Lock()
+		truncate := s.truncate
+		s.mutex.Unlock()
+		if truncate {
+			_, questionEnd, _ := readDNSName(response, dnsHeaderSize)
+			response = response[:questionEnd+4]
+			binary.BigEndian.PutUint16(response[2:], binary.BigEndian.Uint16(response[2:])|0x0200)
+			binary.BigEndian.PutUint16(response[6:], 0)
+		}
+		s.conn.WriteTo(response, addr)
+	}
+}
+
+func (s *fakeDNSServer) serveTCP() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, query); err != nil {
+				return
+			}
+			response := s.answer(query)
+			message := make([]byte, 2, 2+len(response))
+			binary.BigEndian.PutUint16(message, uint16(len(response)))
+			conn.Write(append(message, response...))
+		}()
+	}
+}
+
+func (s *fakeDNSServer) answer(query []byte) []byte {
+	name, questionEnd, _ := readDNSName(query, dnsHeaderSize)
+	queryType := binary.BigEndian.Uint16(query[questionEnd:])
+	questionEnd += 4
+	name = strings.TrimSuffix(name, ".")
+
+	s.mutex.Lock()
+	s.queries++
+	records, ok := s.records[name]
+	ip, hasIP := s.ips[name]
+	s.mutex.Unlock()
+
+	response := append([]byte(nil), query[:questionEnd]...)
+	// Response, recursion desired and available, and NXDOMAIN for unknown names
+	flags := uint16(0x8180)
+	if !ok && !hasIP {
+		flags |= 3
+	}
+	binary.BigEndian.PutUint16(response[2:], flags)
+	// Only answers, even if the query had additional records
+	binary.BigEndian.PutUint16(response[8:], 0)
+	binary.BigEndian.PutUint16(response[10:], 0)
+
+	switch {
+	case queryType == 1 && hasIP:
+		binary.BigEndian.PutUint16(response[6:], 1)
+		answer := make([]byte, 12)
+		binary.BigEndian.PutUint16(answer[0:], 0xC000|dnsHeaderSize)
+		binary.BigEndian.PutUint16(answer[2:], 1)
+		binary.BigEndian.PutUint16(answer[4:], dnsClassINET)
+		binary.BigEndian.PutUint32(answer[6:], 60)
+		binary.BigEndian.PutUint16(answer[10:], 4)
+		response = append(response, answer...)
+		return append(response, ip.To4()...)
+	case queryType != dnsTypeSRV:
+		binary.BigEndian.PutUint16(response[6:], 0)
+		return response
+	}
+	binary.BigEndian.PutUint16(response[6:], uint16(len(records)))
+
+	for _, record := range records {
+		target, _ := encodeDNSName(record.Target)
+		data := make([]byte, 6, 6+len(target))
+		binary.BigEndian.PutUint16(data[0:], record.Priority)
+		binary.BigEndian.PutUint16(data[2:], record.Weight)
+		binary.BigEndian.PutUint16(data[4:], record.Port)
+		data = append(data, target...)
+
+		answer := make([]byte, 12)
+		// Pointer to the name in the question
+		binary.BigEndian.PutUint16(answer[0:], 0xC000|dnsHeaderSize)
+		binary.BigEndian.PutUint16(answer[2:], dnsTypeSRV)
+		binary.BigEndian.PutUint16(answer[4:], dnsClassINET)
+		binary.BigEndian.PutUint32(answer[6:], uint32(record.TTL/time.Second))
+		binary.BigEndian.PutUint16(answer[10:], uint16(len(data)))
+		response = append(response, answer...)
+		response = append(response, data...)
+	}
+	return response
+}
+
+func srvRecordFor(server *httptest.Server, priority uint16, ttl time.Duration) SRVRecord {
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := net.LookupPort("tcp", port)
+	return SRVRecord{Target: host + ".", Port: uint16(portNumber), Priority: priority, Weight: 1, TTL: ttl}
+}
+
+func TestSRVDiscovery(t *testing.T) {
+
+	// Initialization
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("primary " + r.URL.Path))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secondary " + r.URL.Path))
+	}))
+	defer secondary.Close()
+
+	dns := newFakeDNSServer(t)
+	defer dns.Close()
+	dns.Set("_api._tcp.example.com", srvRecordFor(primary, 1, time.Second), srvRecordFor(secondary, 2, time.Second))
+
+	c := NewBuilder().
+		SetEndpointSource(RoundRobin, &SRVSource{
+			Service:  "api",
+			Proto:    "tcp",
+			Name:     "example.com",
+			Scheme:   "http",
+			Path:     "/v1",
+			Resolver: &DNSResolver{Server: dns.Addr()},
+		}).
+		Build()
+	defer c.Close()
+
+	get := func() string {
+		resp, err := c.GET("/users", nil)
+		if err != nil {
+			t.Fatalf("Error executing GET: %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body)
+	}
+
+	// Execution
+	first, second := get(), get()
+	dns.Set("_api._tcp.example.com", srvRecordFor(secondary, 1, time.Second))
+	var refreshed string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if refreshed = get(); refreshed == "secondary /v1/users" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Validation
+	if first != "primary /v1/users" || second != "primary /v1/users" {
+		t.Errorf("Requests should go to the endpoint with the lowest priority value: %q, %q", first, second)
+	}
+	if refreshed != "secondary /v1/users" {
+		t.Errorf("Endpoints were not refreshed after the TTL, got %q", refreshed)
+	}
+}
+
+func TestDNSResolverTruncatedResponse(t *testing.T) {
+
+	// Initialization
+	dns := newFakeDNSServer(t)
+	defer dns.Close()
+	records := make([]SRVRecord, 40)
+	for i := range records {
+		records[i] = SRVRecord{Target: fmt.Sprintf("node-%02d.api.example.com.", i), Port: 8080, Priority: 1, Weight: 1, TTL: time.Minute}
+	}
+	dns.Set("_api._tcp.example.com", records...)
+	dns.TruncateUDP(true)
+
+	// Execution
+	found, err := (&DNSResolver{Server: dns.Addr()}).LookupSRV(context.Background(), "_api._tcp.example.com")
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Truncated response should be asked again over TCP, got %v", err)
+	}
+	if len(found) != len(records) || found[39].Target != "node-39.api.example.com." {
+		t.Errorf("Every record should be found, got %d", len(found))
+	}
+	if dns.Queries() != 2 {
+		t.Errorf("One UDP and one TCP query expected, got %d", dns.Queries())
+	}
+}
+
+func TestDNSResolverErrors(t *testing.T) {
+
+	// Initialization
+	dns := newFakeDNSServer(t)
+	defer dns.Close()
+
+	c := NewBuilder().
+		SetEndpointSource(RoundRobin, &SRVSource{
+			Service:  "missing",
+			Proto:    "tcp",
+			Name:     "example.com",
+			Resolver: &DNSResolver{Server: dns.Addr()},
+		}).
+		Build()
+	defer c.Close()
+
+	// Execution
+	_, lookupErr := (&DNSResolver{Server: dns.Addr()}).LookupSRV(context.Background(), "_missing._tcp.example.com")
+	_, requestErr := c.GET("/users", nil)
+
+	// Validation
+	if lookupErr == nil || lookupErr.Error() != "name not found" {
+		t.Errorf("Invalid lookup error %v", lookupErr)
+	}
+	if requestErr == nil || !strings.Contains(requestErr.Error(), "no endpoints available") {
+		t.Errorf("Invalid request error %v", requestErr)
+	}
+}
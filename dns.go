@@ -0,0 +1,290 @@
+package gohttpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	dnsTypeSRV   uint16 = 33
+	dnsTypeOPT   uint16 = 41
+	dnsClassINET uint16 = 1
+
+	dnsHeaderSize     = 12
+	maxDNSMessageSize = 4096
+
+	defaultDNSServer string = "127.0.0.1:53"
+	resolvConfPath   string = "/etc/resolv.conf"
+)
+
+// DNSResolver is an SRVResolver querying a name server over UDP, advertising EDNS0
+// responses of up to 4096 bytes, and over TCP when the response doesn't fit anyway.
+// Unlike net.Resolver, it returns the records TTL.
+type DNSResolver struct {
+	// Server address, like "10.0.0.2:53".
+	Server string
+
+	// Timeout of every query. Default is 5 seconds.
+	Timeout time.Duration
+}
+
+// SystemDNSResolver returns a DNSResolver using the first name server in
+// /etc/resolv.conf, or a local one if there's none.
+func SystemDNSResolver() *DNSResolver {
+	server := defaultDNSServer
+	if file, err := os.Open(resolvConfPath); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				server = net.JoinHostPort(fields[1], "53")
+				break
+			}
+		}
+	}
+	return &DNSResolver{Server: server}
+}
+
+func (r *DNSResolver) LookupSRV(ctx context.Context, name string) ([]SRVRecord, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = discoveryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	query, id, err := newDNSQuery(name, dnsTypeSRV)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := r.exchange(ctx, "udp", query, id)
+	if err != nil {
+		return nil, err
+	}
+	records, err := parseSRVResponse(response)
+	if err == errTruncatedDNSResponse {
+		if response, err = r.exchange(ctx, "tcp", query, id); err != nil {
+			return nil, err
+		}
+		records, err = parseSRVResponse(response)
+	}
+	return records, err
+}
+
+// errTruncatedDNSResponse is the error of a response that didn't fit in a UDP message.
+var errTruncatedDNSResponse = errors.New("truncated DNS response")
+
+// exchange sends the query with the ID to the name server over network, "udp" or "tcp",
+// and returns the response.
+func (r *DNSResolver) exchange(ctx context.Context, network string, query []byte, id uint16) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, r.Server)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to name server. %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		// Messages are prefixed with their length over TCP
+		message := make([]byte, 2, 2+len(query))
+		binary.BigEndian.PutUint16(message, uint16(len(query)))
+		if _, err := conn.Write(append(message, query...)); err != nil {
+			return nil, fmt.Errorf("unable to send DNS query. %v", err)
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, fmt.Errorf("unable to read DNS response. %v", err)
+		}
+		response := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, response); err != nil {
+			return nil, fmt.Errorf("unable to read DNS response. %v", err)
+		}
+		if len(response) < dnsHeaderSize || binary.BigEndian.Uint16(response) != id {
+			return nil, errors.New("invalid DNS response")
+		}
+		return response, nil
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("unable to send DNS query. %v", err)
+	}
+
+	buffer := make([]byte, maxDNSMessageSize)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read DNS response. %v", err)
+		}
+		// Responses to other queries are ignored
+		if n < dnsHeaderSize || binary.BigEndian.Uint16(buffer) != id {
+			continue
+		}
+		return buffer[:n], nil
+	}
+}
+
+// newDNSQuery returns a recursive EDNS0 query for the records of the type with the name,
+// and its ID.
+func newDNSQuery(name string, recordType uint16) ([]byte, uint16, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	header := make([]byte, dnsHeaderSize)
+	binary.BigEndian.PutUint16(header[0:], id)
+	// Recursion desired
+	binary.BigEndian.PutUint16(header[2:], 0x0100)
+	// One question, and one additional OPT record
+	binary.BigEndian.PutUint16(header[4:], 1)
+	binary.BigEndian.PutUint16(header[10:], 1)
+
+	encodedName, err := encodeDNSName(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := append(header, encodedName...)
+	query = append(query, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(query[len(query)-4:], recordType)
+	binary.BigEndian.PutUint16(query[len(query)-2:], dnsClassINET)
+
+	// EDNS0 OPT record of the root name, RFC 6891, with the UDP payload size as class,
+	// and no extended flags nor options
+	opt := make([]byte, 11)
+	binary.BigEndian.PutUint16(opt[1:], dnsTypeOPT)
+	binary.BigEndian.PutUint16(opt[3:], maxDNSMessageSize)
+	return append(query, opt...), id, nil
+}
+
+func encodeDNSName(name string) ([]byte, error) {
+	var encoded []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid DNS name %q", name)
+		}
+		encoded = append(encoded, byte(len(label)))
+		encoded = append(encoded, label...)
+	}
+	return append(encoded, 0), nil
+}
+
+// parseSRVResponse returns the SRV records in the answer section of a DNS response.
+func parseSRVResponse(message []byte) ([]SRVRecord, error) {
+	flags := binary.BigEndian.Uint16(message[2:])
+	if flags&0x0200 != 0 {
+		return nil, errTruncatedDNSResponse
+	}
+	switch rcode := flags & 0x000F; rcode {
+	case 0:
+	case 3:
+		return nil, errors.New("name not found")
+	default:
+		return nil, fmt.Errorf("name server failed with code %d", rcode)
+	}
+	questions := binary.BigEndian.Uint16(message[4:])
+	answers := binary.BigEndian.Uint16(message[6:])
+
+	offset := dnsHeaderSize
+	for i := 0; i < int(questions); i++ {
+		_, next, err := readDNSName(message, offset)
+		if err != nil {
+			return nil, err
+		}
+		// Type and class
+		offset = next + 4
+	}
+
+	var records []SRVRecord
+	for i := 0; i < int(answers); i++ {
+		_, next, err := readDNSName(message, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next
+		if offset+10 > len(message) {
+			return nil, errors.New("invalid DNS response")
+		}
+		recordType := binary.BigEndian.Uint16(message[offset:])
+		ttl := binary.BigEndian.Uint32(message[offset+4:])
+		length := int(binary.BigEndian.Uint16(message[offset+8:]))
+		offset += 10
+		if offset+length > len(message) {
+			return nil, errors.New("invalid DNS response")
+		}
+		data := offset
+		offset += length
+
+		if recordType != dnsTypeSRV {
+			continue
+		}
+		if length < 7 {
+			return nil, errors.New("invalid SRV record")
+		}
+		target, _, err := readDNSName(message, data+6)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, SRVRecord{
+			Priority: binary.BigEndian.Uint16(message[data:]),
+			Weight:   binary.BigEndian.Uint16(message[data+2:]),
+			Port:     binary.BigEndian.Uint16(message[data+4:]),
+			Target:   target,
+			TTL:      time.Duration(ttl) * time.Second,
+		})
+	}
+	return records, nil
+}
+
+// readDNSName reads the possibly compressed name at offset, returning it and
+// the offset right after it.
+func readDNSName(message []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	// Limits the pointers followed, so a malicious message can't make a loop
+	for jumps := 0; jumps < 64; {
+		if offset >= len(message) {
+			return "", 0, errors.New("invalid DNS name")
+		}
+		length := int(message[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(message) {
+				return "", 0, errors.New("invalid DNS name")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(message[offset:]) & 0x3FFF)
+			jumps++
+
+		default:
+			if offset+1+length > len(message) {
+				return "", 0, errors.New("invalid DNS name")
+			}
+			labels = append(labels, string(message[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+	return "", 0, errors.New("too many DNS name pointers")
+}
@@ -0,0 +1,682 @@
+package gohttpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDownloadRetries    int           = 5
+	defaultDownloadRetryDelay time.Duration = time.Second
+
+	downloadBufferSize = 32 << 10
+)
+
+// DownloadOptions configures a download.
+type DownloadOptions struct {
+	// Headers sent with every request.
+	Headers http.Header
+
+	// Chunks is the number of parallel ranged requests the download is split into,
+	// when the server supports ranges and tells the size and a validator of the file.
+	// A partial file left by DownloadFile is resumed with a single request.
+	// Default is 1, a single request.
+	Chunks int
+
+	// MaxRetries is the number of times a request failing without making progress is
+	// resumed. Default is 5.
+	MaxRetries int
+
+	// RetryDelay is the time to wait before resuming a failed request. Default is 1 second.
+	RetryDelay time.Duration
+
+	// Checksum the file must match, as the algorithm and the hex encoded hash, like
+	// "sha256:9f86d08...". Supported algorithms are md5, sha1, sha256 and sha512.
+	// If empty, the Digest or Content-MD5 header of the response is used, if any.
+	Checksum string
+
+	// Progress, if set, is called as bytes are written, at most once per
+	// ProgressInterval, and always once the download completes.
+	Progress func(Progress)
+
+	// ProgressInterval is the minimum time between calls to Progress.
+	// Default is 100 milliseconds.
+	ProgressInterval time.Duration
+}
+
+// ChecksumError is returned when a downloaded file doesn't match its checksum.
+type ChecksumError struct {
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch. Expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// Download downloads url into w, resuming with Range and If-Range requests after
+// network failures, and returns the number of bytes written.
+// With options.Chunks greater than 1 the download is split into parallel ranged
+// requests, and verifying its checksum needs w to be an io.ReaderAt too, like *os.File.
+func Download(ctx context.Context, client Doer, url string, w io.WriterAt, options DownloadOptions) (int64, error) {
+	d, err := newDownloader(client, url, w, options)
+	if err != nil {
+		return 0, err
+	}
+	return d.run(ctx)
+}
+
+// DownloadFile downloads url into the file at path, like Download. The file is written
+// with a .part suffix, and renamed once complete and verified.
+// On failure the .part file is kept, and the next call resumes it with Range and
+// If-Range requests, provided the server told a validator of the file. It's removed
+// only when it doesn't match the checksum.
+func DownloadFile(ctx context.Context, client Doer, url string, path string, options DownloadOptions) (int64, error) {
+	partPath := path + ".part"
+	validatorPath := partPath + ".validator"
+
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open file. %v", err)
+	}
+	d, err := newDownloader(client, url, file, options)
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		if validator, err := ioutil.ReadFile(validatorPath); err == nil && len(validator) > 0 {
+			d.resumeOffset, d.resumeValidator = info.Size(), string(validator)
+		}
+	}
+	d.validatorChanged = func(validator string) {
+		// A validator that can't be saved only prevents resuming the file
+		if validator == "" {
+			os.Remove(validatorPath)
+		} else {
+			ioutil.WriteFile(validatorPath, []byte(validator), 0666)
+		}
+	}
+
+	written, err := d.run(ctx)
+	if err == nil {
+		// The file is longer than the download if it was started over
+		if err = file.Truncate(written); err != nil {
+			err = fmt.Errorf("unable to write file. %v", err)
+		}
+	}
+	closeErr := file.Close()
+	if err == nil && closeErr != nil {
+		err = fmt.Errorf("unable to write file. %v", closeErr)
+	}
+	if err == nil {
+		err = os.Rename(partPath, path)
+	}
+	if err != nil {
+		var checksumErr *ChecksumError
+		if errors.As(err, &checksumErr) {
+			os.Remove(partPath)
+			os.Remove(validatorPath)
+		}
+		return written, err
+	}
+	os.Remove(validatorPath)
+	return written, nil
+}
+
+type downloader struct {
+	client  Doer
+	url     string
+	w       io.WriterAt
+	options DownloadOptions
+
+	// checksum is the expected one, from the options or the response headers.
+	checksum *checksum
+
+	// resumeOffset is the size of the file written by a previous download,
+	// resumed if it's still the version with resumeValidator.
+	resumeOffset    int64
+	resumeValidator string
+
+	// validatorChanged, if set, is called with the validator of the file written,
+	// or an empty one when it can't be resumed.
+	validatorChanged func(validator string)
+
+	mutex       sync.Mutex
+	transferred int64
+	total       int64
+	start       time.Time
+	// resumed is the number of bytes transferred by a previous download.
+	resumed    int64
+	lastReport time.Time
+}
+
+func newDownloader(client Doer, url string, w io.WriterAt, options DownloadOptions) (*downloader, error) {
+	d := &downloader{
+		client:  client,
+		url:     url,
+		w:       w,
+		options: options,
+		total:   -1,
+		start:   time.Now(),
+	}
+	if options.MaxRetries <= 0 {
+		d.options.MaxRetries = defaultDownloadRetries
+	}
+	if options.RetryDelay <= 0 {
+		d.options.RetryDelay = defaultDownloadRetryDelay
+	}
+	if options.ProgressInterval <= 0 {
+		d.options.ProgressInterval = defaultProgressInterval
+	}
+	if options.Checksum != "" {
+		expected, err := parseChecksumOption(options.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		d.checksum = expected
+	}
+	return d, nil
+}
+
+func (d *downloader) run(ctx context.Context) (int64, error) {
+	if d.options.Chunks > 1 && d.resumeOffset == 0 {
+		if size, validator, ok := d.probeRanges(ctx); ok {
+			// The ranges written can't be told from the size of the file
+			d.setValidator("")
+			return d.parallel(ctx, size, validator)
+		}
+	}
+	return d.single(ctx)
+}
+
+type checksum struct {
+	algorithm string
+	newHash   func() hash.Hash
+	expected  []byte
+}
+
+// single downloads the file with a single request, resumed from where it failed.
+func (d *downloader) single(ctx context.Context) (int64, error) {
+	offset := d.resumeOffset
+	validator := d.resumeValidator
+	d.setTransferred(offset)
+	// The bytes written by a previous download aren't hashed, so they're read back
+	var streamHash hash.Hash
+	retries := 0
+
+	for {
+		headers := d.headers()
+		if offset > 0 {
+			headers.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			if validator != "" {
+				headers.Set("If-Range", validator)
+			}
+		}
+
+		response, err := d.client.Do(WithStreaming(ctx), http.MethodGet, d.url, headers, nil)
+		if err == nil {
+			switch {
+			case response.StatusCode == http.StatusOK:
+				// Either the first request, or the file changed and is downloaded again
+				offset = 0
+				d.setTotal(response.ContentLength)
+				d.setTransferred(0)
+				validator = rangeValidator(response)
+				d.setValidator(validator)
+				if d.checksum == nil {
+					d.checksum = checksumFromHeaders(response.Header)
+				}
+				streamHash = nil
+				if d.checksum != nil {
+					streamHash = d.checksum.newHash()
+				}
+
+			case response.StatusCode == http.StatusPartialContent && offset > 0:
+				start, _, size, ok := parseContentRange(response.Header.Get("Content-Range"))
+				if !ok || start != offset {
+					discardBody(response)
+					return offset, fmt.Errorf("unable to resume download. Invalid Content-Range %q", response.Header.Get("Content-Range"))
+				}
+				d.setTotal(size)
+
+			case response.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+				// The file resumed is no shorter than the current one, which is downloaded again
+				discardBody(response)
+				offset, validator = 0, ""
+				continue
+
+			default:
+				discardBody(response)
+				err = fmt.Errorf("download failed with status %d", response.StatusCode)
+				if !isRetryableStatus(response.StatusCode) {
+					return offset, err
+				}
+			}
+		}
+
+		if err == nil {
+			var written int64
+			written, err = d.copy(response.Body, offset, streamHash)
+			response.Body.Close()
+			offset += written
+			if written > 0 {
+				retries = 0
+			}
+			if err == nil {
+				if total := d.getTotal(); total >= 0 && offset < total {
+					err = io.ErrUnexpectedEOF
+				} else {
+					d.report(true)
+					if streamHash == nil {
+						return offset, d.verifyWritten(offset)
+					}
+					return offset, d.verifyHash(streamHash)
+				}
+			}
+		}
+
+		if ctx.Err() != nil {
+			return offset, ctx.Err()
+		}
+		retries++
+		if retries > d.options.MaxRetries {
+			return offset, fmt.Errorf("unable to download %s. %v", d.url, err)
+		}
+		if err := sleepContext(ctx, d.options.RetryDelay); err != nil {
+			return offset, err
+		}
+	}
+}
+
+// probeRanges tells whether the file can be downloaded in parallel ranges,
+// returning its size and a validator making sure every range is of the same file.
+func (d *downloader) probeRanges(ctx context.Context) (int64, string, bool) {
+	response, err := d.client.Do(ctx, http.MethodHead, d.url, d.headers(), nil)
+	if err != nil {
+		return 0, "", false
+	}
+	discardBody(response)
+
+	validator := rangeValidator(response)
+	if response.StatusCode != http.StatusOK || response.ContentLength <= 0 || validator == "" ||
+		!strings.EqualFold(response.Header.Get("Accept-Ranges"), "bytes") {
+		return 0, "", false
+	}
+	if d.checksum == nil {
+		// Content-MD5 of a HEAD response is the one of the whole file
+		d.checksum = checksumFromHeaders(response.Header)
+	}
+	return response.ContentLength, validator, true
+}
+
+// parallel downloads the file in ranges at the same time.
+func (d *downloader) parallel(ctx context.Context, size int64, validator string) (int64, error) {
+	d.setTotal(size)
+
+	chunks := int64(d.options.Chunks)
+	if chunks > size {
+		chunks = size
+	}
+	chunkSize := size / chunks
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, chunks)
+	for i := int64(0); i < chunks; i++ {
+		start := i * chunkSize
+		end := start + chunkSize - 1
+		if i == chunks-1 {
+			end = size - 1
+		}
+		go func() {
+			err := d.chunk(ctx, start, end, validator)
+			if err != nil {
+				// Other chunks are useless once one failed
+				cancel()
+			}
+			errs <- err
+		}()
+	}
+
+	var firstErr error
+	for i := int64(0); i < chunks; i++ {
+		if err := <-errs; err != nil && (firstErr == nil || firstErr == context.Canceled) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return d.getTransferred(), firstErr
+	}
+	d.report(true)
+	return size, d.verifyWritten(size)
+}
+
+// chunk downloads the bytes from start to end, both included, resumed from
+// where it failed.
+func (d *downloader) chunk(ctx context.Context, start int64, end int64, validator string) error {
+	offset := start
+	retries := 0
+	for offset <= end {
+		headers := d.headers()
+		headers.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+		headers.Set("If-Range", validator)
+
+		response, err := d.client.Do(WithStreaming(ctx), http.MethodGet, d.url, headers, nil)
+		if err == nil {
+			switch {
+			case response.StatusCode == http.StatusPartialContent:
+				rangeStart, _, _, ok := parseContentRange(response.Header.Get("Content-Range"))
+				if !ok || rangeStart != offset {
+					discardBody(response)
+					return fmt.Errorf("unable to download range. Invalid Content-Range %q", response.Header.Get("Content-Range"))
+				}
+				var written int64
+				written, err = d.copy(io.LimitReader(response.Body, end-offset+1), offset, nil)
+				response.Body.Close()
+				offset += written
+				if written > 0 {
+					retries = 0
+				}
+				if err == nil && offset <= end {
+					err = io.ErrUnexpectedEOF
+				}
+
+			case response.StatusCode == http.StatusOK:
+				discardBody(response)
+				return errors.New("unable to download range. The file changed during the download")
+
+			default:
+				discardBody(response)
+				err = fmt.Errorf("download failed with status %d", response.StatusCode)
+				if !isRetryableStatus(response.StatusCode) {
+					return err
+				}
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		retries++
+		if retries > d.options.MaxRetries {
+			return fmt.Errorf("unable to download %s. %v", d.url, err)
+		}
+		if err := sleepContext(ctx, d.options.RetryDelay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy writes body at offset, returning the number of bytes written.
+func (d *downloader) copy(body io.Reader, offset int64, streamHash hash.Hash) (int64, error) {
+	buffer := make([]byte, downloadBufferSize)
+	var written int64
+	for {
+		n, readErr := body.Read(buffer)
+		if n > 0 {
+			if _, err := d.w.WriteAt(buffer[:n], offset+written); err != nil {
+				return written, fmt.Errorf("unable to write download. %v", err)
+			}
+			if streamHash != nil {
+				streamHash.Write(buffer[:n])
+			}
+			written += int64(n)
+			d.addTransferred(int64(n))
+		}
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+}
+
+func (d *downloader) headers() http.Header {
+	headers := d.options.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	// Offsets are of the file itself, not of a compressed representation
+	headers.Set("Accept-Encoding", "identity")
+	return headers
+}
+
+func (d *downloader) setTotal(total int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.total = total
+}
+
+func (d *downloader) getTotal() int64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.total
+}
+
+func (d *downloader) getTransferred() int64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.transferred
+}
+
+// setTransferred sets the bytes already in the file when the download starts or
+// starts over. They don't count in the transfer rate.
+func (d *downloader) setTransferred(transferred int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.transferred = transferred
+	d.resumed = transferred
+}
+
+func (d *downloader) addTransferred(n int64) {
+	d.mutex.Lock()
+	d.transferred += n
+	d.mutex.Unlock()
+	d.report(false)
+}
+
+// report calls the Progress option, if set, unless it was called less than
+// ProgressInterval ago and the download isn't done.
+func (d *downloader) report(done bool) {
+	if d.options.Progress == nil {
+		return
+	}
+	d.mutex.Lock()
+	now := time.Now()
+	if !done && now.Sub(d.lastReport) < d.options.ProgressInterval {
+		d.mutex.Unlock()
+		return
+	}
+	d.lastReport = now
+	progress := Progress{
+		Transferred: d.transferred,
+		Total:       d.total,
+		Rate:        transferRate(d.transferred-d.resumed, now.Sub(d.start)),
+	}
+	d.mutex.Unlock()
+
+	d.options.Progress(progress)
+}
+
+func (d *downloader) setValidator(validator string) {
+	if d.validatorChanged != nil {
+		d.validatorChanged(validator)
+	}
+}
+
+func (d *downloader) verifyHash(streamHash hash.Hash) error {
+	if d.checksum == nil || streamHash == nil {
+		return nil
+	}
+	return d.checksum.verify(streamHash.Sum(nil))
+}
+
+// verifyWritten verifies the checksum of the bytes written, reading them back.
+func (d *downloader) verifyWritten(size int64) error {
+	if d.checksum == nil {
+		return nil
+	}
+	readerAt, ok := d.w.(io.ReaderAt)
+	if !ok {
+		return errors.New("unable to verify checksum. The writer of a parallel download must be an io.ReaderAt")
+	}
+	h := d.checksum.newHash()
+	if _, err := io.Copy(h, io.NewSectionReader(readerAt, 0, size)); err != nil {
+		return fmt.Errorf("unable to verify checksum. %v", err)
+	}
+	return d.checksum.verify(h.Sum(nil))
+}
+
+func (c *checksum) verify(actual []byte) error {
+	if !bytes.Equal(actual, c.expected) {
+		return &ChecksumError{
+			Algorithm: c.algorithm,
+			Expected:  hex.EncodeToString(c.expected),
+			Actual:    hex.EncodeToString(actual),
+		}
+	}
+	return nil
+}
+
+// Hash functions by name, in the Checksum option and the Digest header.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha1":    sha1.New,
+	"sha":     sha1.New,
+	"sha256":  sha256.New,
+	"sha-256": sha256.New,
+	"sha512":  sha512.New,
+	"sha-512": sha512.New,
+}
+
+func parseChecksumOption(option string) (*checksum, error) {
+	parts := strings.SplitN(option, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid checksum %q. It must be algorithm:hex", option)
+	}
+	algorithm := strings.ToLower(parts[0])
+	newHash, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checksum algorithm %s", parts[0])
+	}
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum %q. %v", option, err)
+	}
+	return &checksum{algorithm: algorithm, newHash: newHash, expected: expected}, nil
+}
+
+// checksumFromHeaders returns the checksum in the Digest header, as described in
+// RFC 3230, preferring the strongest algorithm, or else in the Content-MD5 header,
+// which is only the one of the whole file in a 200 or HEAD response.
+func checksumFromHeaders(headers http.Header) *checksum {
+	var best *checksum
+	strength := map[string]int{"md5": 1, "sha": 2, "sha-256": 3, "sha-512": 4}
+	for _, value := range headers.Values("Digest") {
+		for _, digest := range strings.Split(value, ",") {
+			parts := strings.SplitN(strings.TrimSpace(digest), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			algorithm := strings.ToLower(parts[0])
+			if strength[algorithm] == 0 || (best != nil && strength[algorithm] <= strength[best.algorithm]) {
+				continue
+			}
+			expected, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				continue
+			}
+			best = &checksum{algorithm: algorithm, newHash: checksumAlgorithms[algorithm], expected: expected}
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	if contentMD5 := headers.Get("Content-MD5"); contentMD5 != "" {
+		if expected, err := base64.StdEncoding.DecodeString(contentMD5); err == nil {
+			return &checksum{algorithm: "md5", newHash: md5.New, expected: expected}
+		}
+	}
+	return nil
+}
+
+// rangeValidator returns the validator to send in If-Range: a strong ETag, or
+// the Last-Modified date.
+func rangeValidator(response *http.Response) string {
+	if etag := response.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return response.Header.Get("Last-Modified")
+}
+
+// parseContentRange parses a Content-Range header like "bytes 100-199/1000".
+// The size is -1 if unknown.
+func parseContentRange(value string) (int64, int64, int64, bool) {
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, 0, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, 0, false
+	}
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, false
+	}
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	end, err := strconv.ParseInt(bounds[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	size := int64(-1)
+	if parts[1] != "*" {
+		if size, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+	return start, end, size, true
+}
+
+func isRetryableStatus(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
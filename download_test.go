@@ -0,0 +1,293 @@
+package gohttpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writerAtBuffer is an in-memory io.WriterAt.
+type writerAtBuffer struct {
+	mutex sync.Mutex
+	data  []byte
+}
+
+func (b *writerAtBuffer) WriteAt(p []byte, offset int64) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if end := int(offset) + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	return copy(b.data[offset:], p), nil
+}
+
+// newFileServer serves content with range support. Requests are recorded, and the ones
+// fail answers true to are aborted halfway through the body.
+func newFileServer(content []byte, fail func(r *http.Request) bool) (*httptest.Server, func() []*http.Request) {
+	var mutex sync.Mutex
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests = append(requests, r)
+		mutex.Unlock()
+
+		w.Header().Set("ETag", `"v1"`)
+		if fail != nil && fail(r) {
+			w.Header().Set("Content-Length", "100000")
+			w.Write(content[:len(content)/2])
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	return server, func() []*http.Request {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]*http.Request{}, requests...)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+
+	// Initialization
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := sha256.Sum256(content)
+	server, requests := newFileServer(content, func(r *http.Request) bool {
+		return r.Header.Get("Range") == ""
+	})
+	defer server.Close()
+
+	w := &writerAtBuffer{}
+	var progress []Progress
+	options := DownloadOptions{
+		RetryDelay: 10 * time.Millisecond,
+		Checksum:   "sha256:" + hex.EncodeToString(sum[:]),
+		Progress: func(p Progress) {
+			progress = append(progress, p)
+		},
+	}
+
+	// Execution
+	written, err := Download(context.Background(), NewBuilder().Build(), server.URL, w, options)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error downloading: %v", err)
+	}
+	if written != int64(len(content)) || !bytes.Equal(w.data, content) {
+		t.Fatalf("Invalid download of %d bytes", written)
+	}
+	sent := requests()
+	if len(sent) != 2 {
+		t.Fatalf("Download should be resumed once, server got %d requests", len(sent))
+	}
+	if sent[1].Header.Get("Range") != "bytes=50000-" || sent[1].Header.Get("If-Range") != `"v1"` {
+		t.Errorf("Invalid resume headers %v", sent[1].Header)
+	}
+	if last := progress[len(progress)-1]; last.Transferred != int64(len(content)) || last.Total != int64(len(content)) {
+		t.Errorf("Invalid last progress %+v", last)
+	}
+}
+
+func TestDownloadFileParallel(t *testing.T) {
+
+	// Initialization
+	content := bytes.Repeat([]byte("abcdefghij"), 10000)
+	sum := sha256.Sum256(content)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Digest", "md5=invalid, sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file")
+
+	// Execution
+	written, err := DownloadFile(context.Background(), NewBuilder().Build(), server.URL, path, DownloadOptions{Chunks: 3})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error downloading: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading download: %v", err)
+	}
+	if written != int64(len(content)) || !bytes.Equal(data, content) {
+		t.Errorf("Invalid download of %d bytes", written)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Partial file should be renamed, got %v", err)
+	}
+}
+
+func TestDownloadParallelChunks(t *testing.T) {
+
+	// Initialization
+	content := bytes.Repeat([]byte("x"), 1000)
+	server, requests := newFileServer(content, nil)
+	defer server.Close()
+
+	// Execution
+	_, err := Download(context.Background(), NewBuilder().Build(), server.URL, &writerAtBuffer{}, DownloadOptions{Chunks: 3})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error downloading: %v", err)
+	}
+	var ranges []string
+	for _, r := range requests() {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+	}
+	joined := strings.Join(ranges, ",")
+	for _, expected := range []string{"bytes=0-332", "bytes=333-665", "bytes=666-999"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Range %s expected, got %q", expected, ranges)
+		}
+	}
+}
+
+func TestDownloadFileChecksumMismatch(t *testing.T) {
+
+	// Initialization
+	server, _ := newFileServer([]byte("content"), nil)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file")
+	options := DownloadOptions{Checksum: "md5:00000000000000000000000000000000"}
+
+	// Execution
+	_, err := DownloadFile(context.Background(), NewBuilder().Build(), server.URL, path, options)
+
+	// Validation
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) || checksumErr.Algorithm != "md5" {
+		t.Fatalf("Checksum error expected, got %v", err)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Partial file should be removed, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("File should not be created, got %v", err)
+	}
+}
+
+func TestDownloadFileResumesPartialFile(t *testing.T) {
+
+	// Initialization
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	var stalled int32
+	var rangeHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeaders = append(rangeHeaders, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if atomic.CompareAndSwapInt32(&stalled, 0, 1) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	// The first download is stopped once half of the file is written
+	ctx, cancel := context.WithCancel(context.Background())
+	stopHalfway := DownloadOptions{
+		ProgressInterval: time.Nanosecond,
+		Progress: func(p Progress) {
+			if p.Transferred >= int64(len(content)/2) {
+				cancel()
+			}
+		},
+	}
+	path := filepath.Join(t.TempDir(), "file")
+	_, failedErr := DownloadFile(ctx, NewBuilder().Build(), server.URL, path, stopHalfway)
+	part, _ := ioutil.ReadFile(path + ".part")
+
+	// Execution
+	written, err := DownloadFile(context.Background(), NewBuilder().Build(), server.URL, path, DownloadOptions{})
+
+	// Validation
+	if failedErr == nil || !bytes.Equal(part, content[:len(content)/2]) {
+		t.Fatalf("Failed download should keep the partial file, got %d bytes and error %v", len(part), failedErr)
+	}
+	if err != nil {
+		t.Fatalf("Error downloading: %v", err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if written != int64(len(content)) || !bytes.Equal(data, content) {
+		t.Errorf("Invalid download of %d bytes", written)
+	}
+	if expected := fmt.Sprintf("bytes=%d- \"v1\"", len(part)); len(rangeHeaders) != 2 || rangeHeaders[1] != expected {
+		t.Errorf("Partial file should be resumed with %s, got %q", expected, rangeHeaders)
+	}
+	if _, err := os.Stat(path + ".part.validator"); !os.IsNotExist(err) {
+		t.Errorf("Validator file should be removed, got %v", err)
+	}
+}
+
+func TestDownloadProgressInterval(t *testing.T) {
+
+	// Initialization
+	content := bytes.Repeat([]byte("x"), 10*downloadBufferSize)
+	server, _ := newFileServer(content, nil)
+	defer server.Close()
+
+	var progress []Progress
+	options := DownloadOptions{
+		ProgressInterval: time.Hour,
+		Progress: func(p Progress) {
+			progress = append(progress, p)
+		},
+	}
+
+	// Execution
+	_, err := Download(context.Background(), NewBuilder().Build(), server.URL, &writerAtBuffer{}, options)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error downloading: %v", err)
+	}
+	if len(progress) != 2 || progress[1].Transferred != int64(len(content)) {
+		t.Errorf("Progress should be reported on the first write and the end, got %+v", progress)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+
+	// Initialization
+	values := map[string][3]int64{
+		"bytes 0-99/1000": {0, 99, 1000},
+		"bytes 100-199/*": {100, 199, -1},
+	}
+
+	// Execution and validation
+	for value, expected := range values {
+		start, end, size, ok := parseContentRange(value)
+		if !ok || [3]int64{start, end, size} != expected {
+			t.Errorf("Invalid range for %q: %d %d %d", value, start, end, size)
+		}
+	}
+	if _, _, _, ok := parseContentRange("bytes */1000"); ok {
+		t.Error("Unsatisfied range should be invalid")
+	}
+}
@@ -0,0 +1,356 @@
+package gohttpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Balancing is the strategy used to pick the endpoint a request is sent to.
+type Balancing int
+
+const (
+	// RoundRobin sends requests to every endpoint in turn.
+	RoundRobin Balancing = iota
+	// LeastOutstanding sends requests to the endpoint with fewer requests in flight.
+	LeastOutstanding
+	// Random sends requests to a random endpoint.
+	Random
+)
+
+const (
+	defaultEjectionFailures int           = 5
+	defaultEjectionTime     time.Duration = 30 * time.Second
+)
+
+// endpointPool balances requests with a relative URL among base URLs, leaving out
+// for a while the endpoints failing consecutively.
+type endpointPool struct {
+	balancing        Balancing
+	ejectionFailures int
+	ejectionTime     time.Duration
+	now              func() time.Time
+
+	mutex     sync.Mutex
+	endpoints []*endpoint
+	random    *rand.Rand
+
+	// ready is closed once the pool got its first endpoints, or failed to.
+	ready     chan struct{}
+	readyOnce sync.Once
+	lookupErr error
+}
+
+type endpoint struct {
+	baseURL  string
+	priority uint16
+	weight   int
+
+	// Smooth weighted round-robin state
+	currentWeight int
+
+	outstanding         int
+	consecutiveFailures int
+	ejectedUntil        time.Time
+
+	unhealthy      bool
+	probeSuccesses int
+	probeFailures  int
+}
+
+func newEndpointPool(balancing Balancing, ejectionFailures int, ejectionTime time.Duration) *endpointPool {
+	return &endpointPool{
+		balancing:        balancing,
+		ejectionFailures: ejectionFailures,
+		ejectionTime:     ejectionTime,
+		now:              time.Now,
+		random:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		ready:            make(chan struct{}),
+	}
+}
+
+func newStaticEndpoints(baseURLs []string) []Endpoint {
+	endpoints := make([]Endpoint, len(baseURLs))
+	for i, baseURL := range baseURLs {
+		endpoints[i] = Endpoint{BaseURL: baseURL}
+	}
+	return endpoints
+}
+
+// setEndpoints replaces the endpoints of the pool, keeping the state of the ones
+// already in it.
+func (p *endpointPool) setEndpoints(endpoints []Endpoint) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	existing := make(map[string]*endpoint, len(p.endpoints))
+	for _, e := range p.endpoints {
+		existing[e.baseURL] = e
+	}
+
+	updated := make([]*endpoint, len(endpoints))
+	for i, source := range endpoints {
+		baseURL := strings.TrimSuffix(source.BaseURL, "/")
+		e, ok := existing[baseURL]
+		if !ok {
+			e = &endpoint{baseURL: baseURL}
+		}
+		e.priority = source.Priority
+		e.weight = int(source.Weight)
+		updated[i] = e
+	}
+	p.endpoints = updated
+	p.lookupErr = nil
+	p.readyOnce.Do(func() { close(p.ready) })
+}
+
+// setLookupError records why the pool couldn't get endpoints from its source.
+func (p *endpointPool) setLookupError(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.lookupErr = err
+	p.readyOnce.Do(func() { close(p.ready) })
+}
+
+// isRelativeURL tells whether the URL of a request lacks scheme and host,
+// so it's resolved against an endpoint.
+func isRelativeURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && !parsed.IsAbs() && parsed.Host == ""
+}
+
+// sendBalanced sends a request with a relative URL to an endpoint picked by the pool.
+// When it fails with a retryable error the request is sent to another endpoint,
+// trying each one at most once.
+func (c *client) sendBalanced(ctx context.Context, method string, path string, headers http.Header, body []byte) (*http.Response, error) {
+	pool := c.endpointPool
+	select {
+	case <-pool.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	tried := make(map[*endpoint]bool)
+	attempt := attemptFromContext(ctx)
+
+	for {
+		e, last := pool.pick(tried)
+		if e == nil {
+			return nil, pool.noEndpointsError()
+		}
+		tried[e] = true
+
+		response, err := c.sendToEndpoint(withAttempt(ctx, attempt), e, method, path, headers, body)
+		if last || ctx.Err() != nil || !isFailoverable(method, response, err) {
+			return response, err
+		}
+		if err == nil {
+			discardBody(response)
+		}
+		attempt++
+	}
+}
+
+func (c *client) sendToEndpoint(ctx context.Context, e *endpoint, method string, path string, headers http.Header, body []byte) (*http.Response, error) {
+	pool := c.endpointPool
+	pool.acquire(e)
+
+	response, err := c.sendTo(ctx, method, e.baseURL+"/"+strings.TrimPrefix(path, "/"), headers, body)
+
+	// Cancelled requests, like the losers of hedged ones, say nothing about the endpoint
+	if ctx.Err() == nil {
+		pool.report(e, err == nil && response.StatusCode < http.StatusInternalServerError)
+	}
+	if err != nil {
+		pool.release(e)
+		return nil, err
+	}
+
+	var once sync.Once
+	response.Body = &cancelOnCloseBody{
+		ReadCloser: response.Body,
+		cancel:     func() { once.Do(func() { pool.release(e) }) },
+	}
+	return response, nil
+}
+
+// isFailoverable tells whether a failed request can be sent to another endpoint.
+// Idempotent requests are on connection errors and 502, 503 and 504 responses;
+// other requests only when the connection couldn't be established, since the
+// server never got them.
+func isFailoverable(method string, response *http.Response, err error) bool {
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		return isHedgeable(method)
+	}
+	switch response.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isHedgeable(method)
+	}
+	return false
+}
+
+// pick returns the endpoint to send a request to, leaving out the tried ones, and
+// whether it's the last one left.
+// Only the endpoints with the lowest priority value are picked, and among them,
+// proportionally to their weight. As RFC 2782 says, endpoints with zero weight are only
+// picked when none of them has a positive weight. Ejected and unhealthy endpoints are
+// only picked when every other one is.
+func (p *endpointPool) pick(tried map[*endpoint]bool) (*endpoint, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	var available, unavailable []*endpoint
+	for _, e := range p.endpoints {
+		if tried[e] {
+			continue
+		}
+		if e.unhealthy || now.Before(e.ejectedUntil) {
+			unavailable = append(unavailable, e)
+		} else {
+			available = append(available, e)
+		}
+	}
+	last := len(available)+len(unavailable) == 1
+
+	candidates := topPriority(available)
+	if len(candidates) == 0 {
+		candidates = topPriority(unavailable)
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	candidates = positiveWeight(candidates)
+
+	switch p.balancing {
+	case LeastOutstanding:
+		least := candidates[0]
+		for _, e := range candidates[1:] {
+			if e.outstanding*least.balancingWeight() < least.outstanding*e.balancingWeight() {
+				least = e
+			}
+		}
+		return least, last
+	case Random:
+		total := 0
+		for _, e := range candidates {
+			total += e.balancingWeight()
+		}
+		n := p.random.Intn(total)
+		for _, e := range candidates {
+			if n < e.balancingWeight() {
+				return e, last
+			}
+			n -= e.balancingWeight()
+		}
+		return candidates[len(candidates)-1], last
+	default:
+		total := 0
+		var best *endpoint
+		for _, e := range candidates {
+			e.currentWeight += e.balancingWeight()
+			total += e.balancingWeight()
+			if best == nil || e.currentWeight > best.currentWeight {
+				best = e
+			}
+		}
+		best.currentWeight -= total
+		return best, last
+	}
+}
+
+// positiveWeight returns the endpoints with a positive weight, or all of them if none has.
+func positiveWeight(endpoints []*endpoint) []*endpoint {
+	var positive []*endpoint
+	for _, e := range endpoints {
+		if e.weight > 0 {
+			positive = append(positive, e)
+		}
+	}
+	if len(positive) == 0 {
+		return endpoints
+	}
+	return positive
+}
+
+// balancingWeight is the weight of the endpoint, where zero counts as 1 because
+// endpoints with zero weight are only balanced among themselves.
+func (e *endpoint) balancingWeight() int {
+	if e.weight == 0 {
+		return 1
+	}
+	return e.weight
+}
+
+// topPriority returns the endpoints with the lowest priority value.
+func topPriority(endpoints []*endpoint) []*endpoint {
+	var top []*endpoint
+	for _, e := range endpoints {
+		if len(top) == 0 || e.priority < top[0].priority {
+			top = []*endpoint{e}
+		} else if e.priority == top[0].priority {
+			top = append(top, e)
+		}
+	}
+	return top
+}
+
+func (p *endpointPool) noEndpointsError() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.lookupErr != nil {
+		return fmt.Errorf("no endpoints available. %v", p.lookupErr)
+	}
+	return errors.New("no endpoints available")
+}
+
+func (p *endpointPool) list() []*endpoint {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return append([]*endpoint(nil), p.endpoints...)
+}
+
+func (p *endpointPool) acquire(e *endpoint) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	e.outstanding++
+}
+
+func (p *endpointPool) release(e *endpoint) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	e.outstanding--
+}
+
+// report records the result of a request, ejecting the endpoint after
+// too many consecutive failures.
+func (p *endpointPool) report(e *endpoint, success bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if success {
+		e.consecutiveFailures = 0
+		return
+	}
+	e.consecutiveFailures++
+	if p.ejectionFailures > 0 && e.consecutiveFailures >= p.ejectionFailures {
+		e.ejectedUntil = p.now().Add(p.ejectionTime)
+		e.consecutiveFailures = 0
+	}
+}
@@ -0,0 +1,217 @@
+package gohttpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newEndpointServer(name string, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Write([]byte(name + " " + r.URL.RequestURI()))
+	}))
+}
+
+func newTestEndpointPool(balancing Balancing, endpoints ...Endpoint) *endpointPool {
+	pool := newEndpointPool(balancing, 2, time.Minute)
+	pool.setEndpoints(endpoints)
+	return pool
+}
+
+func pickEndpoint(pool *endpointPool, tried map[*endpoint]bool) *endpoint {
+	e, _ := pool.pick(tried)
+	return e
+}
+
+func TestEndpointsRoundRobin(t *testing.T) {
+
+	// Initialization
+	var firstHits, secondHits int32
+	first := newEndpointServer("first", &firstHits)
+	defer first.Close()
+	second := newEndpointServer("second", &secondHits)
+	defer second.Close()
+
+	c := NewBuilder().SetEndpoints(RoundRobin, first.URL+"/api/", second.URL+"/api").Build()
+
+	// Execution
+	bodies := make([]string, 4)
+	for i := range bodies {
+		resp, err := c.GET("/users/1?expand=true", nil)
+		if err != nil {
+			t.Fatalf("Error executing GET: %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		bodies[i] = string(body)
+	}
+
+	// Validation
+	if bodies[0] != "first /api/users/1?expand=true" || bodies[1] != "second /api/users/1?expand=true" {
+		t.Errorf("Invalid bodies %q", bodies)
+	}
+	if atomic.LoadInt32(&firstHits) != 2 || atomic.LoadInt32(&secondHits) != 2 {
+		t.Errorf("Requests were not balanced: %d, %d", firstHits, secondHits)
+	}
+}
+
+func TestEndpointsFailover(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+	healthy := newEndpointServer("healthy", &hits)
+	defer healthy.Close()
+
+	getClient := NewBuilder().SetEndpoints(RoundRobin, unavailable.URL, downURL, healthy.URL).Build()
+	postClient := NewBuilder().SetEndpoints(RoundRobin, downURL, healthy.URL).Build()
+
+	// Execution
+	getResp, getErr := getClient.GET("/resource", nil)
+	postResp, postErr := postClient.POST("/resource", nil, "body")
+
+	// Validation
+	if getErr != nil || getResp.StatusCode != http.StatusOK {
+		t.Fatalf("GET should fail over to the healthy endpoint: %v", getErr)
+	}
+	getResp.Body.Close()
+	if postErr != nil || postResp.StatusCode != http.StatusOK {
+		t.Fatalf("POST should fail over when the connection is refused: %v", postErr)
+	}
+	postResp.Body.Close()
+}
+
+func TestEndpointsNoFailoverForNonIdempotent(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	healthy := newEndpointServer("healthy", &hits)
+	defer healthy.Close()
+
+	c := NewBuilder().SetEndpoints(RoundRobin, unavailable.URL, healthy.URL).Build()
+
+	// Execution
+	resp, err := c.POST("/resource", nil, "body")
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("POST answered by the server should not be sent again, status %d", resp.StatusCode)
+	}
+}
+
+func TestEndpointsOutlierEjection(t *testing.T) {
+
+	// Initialization
+	pool := newTestEndpointPool(RoundRobin, Endpoint{BaseURL: "http://a"}, Endpoint{BaseURL: "http://b"})
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+	a := pool.endpoints[0]
+
+	// Execution
+	pool.report(a, false)
+	pool.report(a, false)
+	picked := []*endpoint{pickEndpoint(pool, nil), pickEndpoint(pool, nil), pickEndpoint(pool, nil)}
+	onlyEjectedLeft := pickEndpoint(pool, map[*endpoint]bool{pool.endpoints[1]: true})
+	now = now.Add(2 * time.Minute)
+	readmitted := false
+	for i := 0; i < 2; i++ {
+		if pickEndpoint(pool, nil) == a {
+			readmitted = true
+		}
+	}
+
+	// Validation
+	for _, e := range picked {
+		if e == a {
+			t.Error("Ejected endpoint should not be picked")
+		}
+	}
+	if onlyEjectedLeft != a {
+		t.Error("Ejected endpoint should be picked when no other is left")
+	}
+	if !readmitted {
+		t.Error("Endpoint should be picked again after the ejection time")
+	}
+}
+
+func TestEndpointsLeastOutstanding(t *testing.T) {
+
+	// Initialization
+	pool := newTestEndpointPool(LeastOutstanding, Endpoint{BaseURL: "http://a"}, Endpoint{BaseURL: "http://b"})
+	pool.acquire(pool.endpoints[0])
+
+	// Execution
+	picked := pickEndpoint(pool, nil)
+
+	// Validation
+	if picked != pool.endpoints[1] {
+		t.Errorf("Endpoint with fewer requests in flight should be picked, got %s", picked.baseURL)
+	}
+}
+
+func TestEndpointsPriorityAndWeight(t *testing.T) {
+
+	// Initialization
+	pool := newTestEndpointPool(RoundRobin,
+		Endpoint{BaseURL: "http://primary-a", Priority: 1, Weight: 3},
+		Endpoint{BaseURL: "http://primary-b", Priority: 1, Weight: 1},
+		Endpoint{BaseURL: "http://backup", Priority: 2},
+	)
+
+	// Execution
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		counts[pickEndpoint(pool, nil).baseURL]++
+	}
+	failover := pickEndpoint(pool, map[*endpoint]bool{pool.endpoints[0]: true, pool.endpoints[1]: true})
+
+	// Validation
+	if counts["http://primary-a"] != 6 || counts["http://primary-b"] != 2 || counts["http://backup"] != 0 {
+		t.Errorf("Requests were not balanced by priority and weight: %v", counts)
+	}
+	if failover.baseURL != "http://backup" {
+		t.Errorf("Lower priority endpoint should be used when no other is left, got %s", failover.baseURL)
+	}
+}
+
+func TestEndpointsZeroWeight(t *testing.T) {
+
+	// Initialization
+	pool := newTestEndpointPool(RoundRobin,
+		Endpoint{BaseURL: "http://weighted", Weight: 5},
+		Endpoint{BaseURL: "http://zero-a"},
+		Endpoint{BaseURL: "http://zero-b"},
+	)
+
+	// Execution
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		counts[pickEndpoint(pool, nil).baseURL]++
+	}
+	for i := 0; i < 4; i++ {
+		counts[pickEndpoint(pool, map[*endpoint]bool{pool.endpoints[0]: true}).baseURL]++
+	}
+
+	// Validation
+	if counts["http://weighted"] != 4 || counts["http://zero-a"] != 2 || counts["http://zero-b"] != 2 {
+		t.Errorf("Zero weight endpoints should be used only without positive weight ones, and equally: %v", counts)
+	}
+}
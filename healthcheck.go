@@ -0,0 +1,198 @@
+package gohttpclient
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval time.Duration = 10 * time.Second
+	defaultHealthCheckTimeout  time.Duration = 2 * time.Second
+	defaultHealthyThreshold    int           = 2
+	defaultUnhealthyThreshold  int           = 3
+)
+
+// HealthCheck configures the probes periodically sent to every endpoint.
+// Endpoints answering with a 2xx or 3xx status are healthy. An endpoint changes state
+// only after a number of probes in a row agree, so a single probe doesn't make it flap.
+type HealthCheck struct {
+	// Path probed on every endpoint, like "/health".
+	Path string
+
+	// Interval between probes. Default is 10 seconds.
+	Interval time.Duration
+
+	// Timeout of every probe. Default is 2 seconds.
+	Timeout time.Duration
+
+	// HealthyThreshold is the number of successful probes in a row making an unhealthy
+	// endpoint healthy again. Default is 2.
+	HealthyThreshold int
+
+	// UnhealthyThreshold is the number of failed probes in a row making a healthy
+	// endpoint unhealthy. Default is 3.
+	UnhealthyThreshold int
+
+	// OnChange, if set, is called every time an endpoint becomes healthy or unhealthy.
+	OnChange func(baseURL string, healthy bool)
+}
+
+// ClientStats is a snapshot of the state of a client.
+type ClientStats struct {
+	Endpoints []EndpointStats
+}
+
+// EndpointStats is the state of an endpoint set with SetEndpoints.
+type EndpointStats struct {
+	BaseURL string
+
+	// Healthy is false once the endpoint failed enough health checks in a row.
+	Healthy bool
+
+	// Ejected is true while the endpoint is left out after consecutive failed requests.
+	Ejected bool
+
+	// Outstanding is the number of requests in flight.
+	Outstanding int
+
+	// ConsecutiveFailures is the number of failed requests in a row.
+	ConsecutiveFailures int
+}
+
+// healthChecker probes the endpoints of a client in the background until closed.
+type healthChecker struct {
+	check HealthCheck
+}
+
+func newHealthChecker(check HealthCheck) *healthChecker {
+	if check.Interval <= 0 {
+		check.Interval = defaultHealthCheckInterval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = defaultHealthCheckTimeout
+	}
+	if check.HealthyThreshold <= 0 {
+		check.HealthyThreshold = defaultHealthyThreshold
+	}
+	if check.UnhealthyThreshold <= 0 {
+		check.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	return &healthChecker{
+		check: check,
+	}
+}
+
+// startHealthChecks probes every endpoint once it has endpoints, and then every interval.
+func (c *client) startHealthChecks() {
+	c.runInBackground(func() {
+		ticker := time.NewTicker(c.healthChecker.check.Interval)
+		defer ticker.Stop()
+		for {
+			c.probeEndpoints()
+			select {
+			case <-ticker.C:
+			case <-c.stop:
+				return
+			}
+		}
+	})
+}
+
+func (c *client) probeEndpoints() {
+	select {
+	case <-c.endpointPool.ready:
+	case <-c.stop:
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, e := range c.endpointPool.list() {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			c.endpointPool.reportHealth(e, c.probe(e), c.healthChecker.check)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// probe tells whether the endpoint answered the health check successfully.
+func (c *client) probe(e *endpoint) bool {
+	checker := c.healthChecker
+	ctx, cancel := context.WithTimeout(context.Background(), checker.check.Timeout)
+	defer cancel()
+
+	// Closing the client aborts the probes in flight
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Probes carry the common headers, signature and known digest credentials of every request
+	request, err := c.newRequest(ctx, http.MethodGet, e.baseURL+checker.check.Path, c.getRequestHeaders(nil), nil)
+	if err != nil {
+		return false
+	}
+	if c.digestAuth != nil {
+		if _, err := c.digestAuth.authorize(request); err != nil {
+			return false
+		}
+	}
+	response, err := c.getHttpClient(ctx).Do(request)
+	if err != nil {
+		return false
+	}
+	discardBody(response)
+	return response.StatusCode >= 200 && response.StatusCode < 400
+}
+
+// reportHealth records the result of a probe, changing the state of the endpoint
+// once enough probes in a row agree.
+func (p *endpointPool) reportHealth(e *endpoint, success bool, check HealthCheck) {
+	p.mutex.Lock()
+	changed := false
+	if success {
+		e.probeFailures = 0
+		e.probeSuccesses++
+		if e.unhealthy && e.probeSuccesses >= check.HealthyThreshold {
+			e.unhealthy = false
+			changed = true
+		}
+	} else {
+		e.probeSuccesses = 0
+		e.probeFailures++
+		if !e.unhealthy && e.probeFailures >= check.UnhealthyThreshold {
+			e.unhealthy = true
+			changed = true
+		}
+	}
+	healthy := !e.unhealthy
+	p.mutex.Unlock()
+
+	if changed && check.OnChange != nil {
+		check.OnChange(e.baseURL, healthy)
+	}
+}
+
+func (p *endpointPool) stats() []EndpointStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	stats := make([]EndpointStats, len(p.endpoints))
+	for i, e := range p.endpoints {
+		stats[i] = EndpointStats{
+			BaseURL:             e.baseURL,
+			Healthy:             !e.unhealthy,
+			Ejected:             now.Before(e.ejectedUntil),
+			Outstanding:         e.outstanding,
+			ConsecutiveFailures: e.consecutiveFailures,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,186 @@
+package gohttpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maxiancillotti/gohttpclient/mock"
+)
+
+func TestHealthCheck(t *testing.T) {
+
+	// Initialization
+	var failing int32
+	var servedByFlaky int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" && atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/health" {
+			atomic.AddInt32(&servedByFlaky, 1)
+		}
+	}))
+	defer flaky.Close()
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stable.Close()
+
+	var mutex sync.Mutex
+	var changes []bool
+	changed := make(chan struct{}, 10)
+	atomic.StoreInt32(&failing, 1)
+
+	c := NewBuilder().
+		SetEndpoints(RoundRobin, flaky.URL, stable.URL).
+		SetHealthCheck(HealthCheck{
+			Path:               "/health",
+			Interval:           10 * time.Millisecond,
+			HealthyThreshold:   2,
+			UnhealthyThreshold: 2,
+			OnChange: func(baseURL string, healthy bool) {
+				if baseURL != flaky.URL {
+					t.Errorf("Unexpected change on %s", baseURL)
+				}
+				mutex.Lock()
+				changes = append(changes, healthy)
+				mutex.Unlock()
+				changed <- struct{}{}
+			},
+		}).
+		Build()
+	defer c.Close()
+
+	// Execution
+	<-changed
+	unhealthyStats := c.Stats()
+	for i := 0; i < 4; i++ {
+		resp, err := c.GET("/resource", nil)
+		if err != nil {
+			t.Fatalf("Error executing GET: %v", err)
+		}
+		resp.Body.Close()
+	}
+	atomic.StoreInt32(&failing, 0)
+	<-changed
+	healthyStats := c.Stats()
+
+	// Validation
+	if unhealthyStats.Endpoints[0].Healthy || !unhealthyStats.Endpoints[1].Healthy {
+		t.Errorf("Invalid stats while unhealthy %+v", unhealthyStats)
+	}
+	if atomic.LoadInt32(&servedByFlaky) != 0 {
+		t.Error("Unhealthy endpoint should not get requests")
+	}
+	if !healthyStats.Endpoints[0].Healthy {
+		t.Errorf("Invalid stats once healthy %+v", healthyStats)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Errorf("Invalid changes %v", changes)
+	}
+}
+
+func TestHealthCheckHeaders(t *testing.T) {
+
+	// Initialization
+	probed := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case probed <- r.Header.Get("X-Api-Key"):
+		default:
+		}
+	}))
+	defer server.Close()
+
+	headers := make(http.Header)
+	headers.Set("X-Api-Key", "secret")
+	c := NewBuilder().
+		SetHeaders(headers).
+		SetEndpoints(RoundRobin, server.URL).
+		SetHealthCheck(HealthCheck{Path: "/health", Interval: 10 * time.Millisecond}).
+		Build()
+	defer c.Close()
+
+	// Execution
+	var apiKey string
+	select {
+	case apiKey = <-probed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Endpoint was not probed")
+	}
+
+	// Validation
+	if apiKey != "secret" {
+		t.Errorf("Probe should carry the common headers, got API key %q", apiKey)
+	}
+}
+
+func TestHealthCheckClose(t *testing.T) {
+
+	// Initialization
+	var probes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+	}))
+	defer server.Close()
+
+	c := NewBuilder().
+		SetEndpoints(RoundRobin, server.URL).
+		SetHealthCheck(HealthCheck{Path: "/health", Interval: 5 * time.Millisecond}).
+		Build()
+	time.Sleep(20 * time.Millisecond)
+
+	// Execution
+	c.Close()
+	// Let the server finish handling probes cancelled by Close
+	time.Sleep(20 * time.Millisecond)
+	afterClose := atomic.LoadInt32(&probes)
+	time.Sleep(30 * time.Millisecond)
+	c.Close()
+
+	// Validation
+	if afterClose == 0 {
+		t.Error("Endpoints were not probed")
+	}
+	if atomic.LoadInt32(&probes) != afterClose {
+		t.Error("Endpoints were probed after closing the client")
+	}
+}
+
+func TestHealthCheckWithMockupServer(t *testing.T) {
+
+	// Initialization
+	mock.MockupServer.Start()
+	defer mock.MockupServer.Stop()
+	mock.MockupServer.AddMock(mock.Mock{Method: http.MethodGet, Url: "https://replica.example.com/resource", ResponseStatusCode: http.StatusOK})
+	defer mock.MockupServer.DeleteMocks()
+
+	c := NewBuilder().
+		SetEndpoints(RoundRobin, "https://replica.example.com").
+		SetHealthCheck(HealthCheck{Path: "/health", Interval: time.Millisecond}).
+		Build()
+
+	// Execution
+	// Probes and requests run concurrently, which the race detector checks
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := c.GET("/resource", nil); err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Validation
+	if err := c.Close(); err != nil {
+		t.Errorf("Error closing client: %v", err)
+	}
+}
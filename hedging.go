@@ -0,0 +1,259 @@
+package gohttpclient
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Latencies kept to compute percentile delays.
+	hedgeLatencySamples = 1000
+	// Latencies needed before a percentile delay is used instead of the fixed one.
+	minHedgeLatencySamples = 20
+)
+
+// HedgePolicy configures hedged requests: when a request has not been answered after
+// a delay, an identical one is sent, the first response is taken, and the other
+// requests are cancelled.
+// Only idempotent requests (GET, HEAD, OPTIONS, TRACE, PUT and DELETE) are hedged.
+type HedgePolicy struct {
+	// Delay to wait for a response before sending a hedged request.
+	Delay time.Duration
+
+	// Percentile of the latencies observed by the client, between 0 and 1, like 0.95,
+	// used as delay once enough latencies have been observed. Delay is used until then.
+	// Zero means Delay is always used.
+	Percentile float64
+
+	// MaxHedges is the maximum number of hedged requests sent per call. Default is 1.
+	MaxHedges int
+
+	// Budget is the maximum ratio of hedged requests to hedgeable calls made by the
+	// client, like 0.1 to hedge at most 10% of them. Zero means no limit.
+	Budget float64
+}
+
+func (p HedgePolicy) enabled() bool {
+	return p.Delay > 0 || p.Percentile > 0
+}
+
+func (p HedgePolicy) maxHedges() int {
+	if p.MaxHedges <= 0 {
+		return 1
+	}
+	return p.MaxHedges
+}
+
+type hedgePolicyKey struct{}
+
+// WithHedgePolicy returns a copy of ctx making requests sent with it use the policy
+// instead of the client one. A zero HedgePolicy disables hedging.
+func WithHedgePolicy(ctx context.Context, policy HedgePolicy) context.Context {
+	return context.WithValue(ctx, hedgePolicyKey{}, policy)
+}
+
+// hedger keeps the latencies observed and the hedging budget of a client.
+type hedger struct {
+	mutex sync.Mutex
+
+	latencies   []time.Duration
+	nextLatency int
+	calls       int64
+	hedgedCalls int64
+}
+
+func newHedger() *hedger {
+	return &hedger{
+		latencies: make([]time.Duration, 0, hedgeLatencySamples),
+	}
+}
+
+type hedgeResult struct {
+	index    int
+	response *http.Response
+	err      error
+	latency  time.Duration
+}
+
+func (c *client) hedgePolicy(ctx context.Context) HedgePolicy {
+	if policy, ok := ctx.Value(hedgePolicyKey{}).(HedgePolicy); ok {
+		return policy
+	}
+	return c.builder.hedgePolicy
+}
+
+func isHedgeable(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sendHedged sends the request, and hedged copies of it while unanswered and allowed
+// by the policy and budget. The first response wins; the other requests are cancelled
+// and their responses discarded. Errors don't win unless every request failed.
+func (c *client) sendHedged(ctx context.Context, policy HedgePolicy, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+	h := c.hedger
+	h.countCall()
+
+	results := make(chan hedgeResult, policy.maxHedges()+1)
+	var cancels []context.CancelFunc
+
+	launch := func() {
+		index := len(cancels)
+		attemptCtx, cancel := context.WithCancel(withAttempt(ctx, attemptFromContext(ctx)+index))
+		cancels = append(cancels, cancel)
+
+		go func() {
+			start := time.Now()
+			response, err := c.fetch(attemptCtx, method, url, headers, body)
+			results <- hedgeResult{index: index, response: response, err: err, latency: time.Since(start)}
+		}()
+	}
+
+	// discardPending cancels the requests still running, and discards their results.
+	discardPending := func(pending int, keep int) {
+		for i, cancel := range cancels {
+			if i != keep {
+				cancel()
+			}
+		}
+		go func() {
+			for ; pending > 0; pending-- {
+				result := <-results
+				if result.err == nil {
+					discardBody(result.response)
+				}
+			}
+		}()
+	}
+
+	launch()
+	pending := 1
+
+	// A nil channel never fires, so no hedged request is sent without a delay
+	var hedgeTimer <-chan time.Time
+	if delay, ok := h.delay(policy); ok {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedgeTimer = timer.C
+	}
+
+	var firstErr error
+	for {
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				h.observe(result.latency)
+				discardPending(pending, result.index)
+				result.response.Body = &cancelOnCloseBody{
+					ReadCloser: result.response.Body,
+					cancel:     cancels[result.index],
+				}
+				return result.response, nil
+			}
+			cancels[result.index]()
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			if len(cancels) <= policy.maxHedges() && h.allowHedge(policy) {
+				launch()
+				pending++
+				if delay, ok := h.delay(policy); ok {
+					hedgeTimer = time.After(delay)
+				}
+			}
+
+		case <-ctx.Done():
+			discardPending(pending, -1)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (h *hedger) countCall() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.calls++
+}
+
+// allowHedge takes a hedge from the budget, if there's any left.
+func (h *hedger) allowHedge(policy HedgePolicy) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if policy.Budget > 0 && float64(h.hedgedCalls+1) > policy.Budget*float64(h.calls) {
+		return false
+	}
+	h.hedgedCalls++
+	return true
+}
+
+func (h *hedger) observe(latency time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.latencies) < hedgeLatencySamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.nextLatency] = latency
+	h.nextLatency = (h.nextLatency + 1) % hedgeLatencySamples
+}
+
+// delay returns how long to wait before sending a hedged request, if one can be sent.
+func (h *hedger) delay(policy HedgePolicy) (time.Duration, bool) {
+	if policy.Percentile > 0 {
+		if percentileDelay, ok := h.percentile(policy.Percentile); ok {
+			return percentileDelay, true
+		}
+	}
+	return policy.Delay, policy.Delay > 0
+}
+
+func (h *hedger) percentile(p float64) (time.Duration, bool) {
+	h.mutex.Lock()
+	if len(h.latencies) < minHedgeLatencySamples {
+		h.mutex.Unlock()
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	h.mutex.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index], true
+}
+
+// cancelOnCloseBody cancels the context of a request once its response body is closed,
+// since cancelling it before would abort reading the body.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,140 @@
+package gohttpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedRequest(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	loserCancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				close(loserCancelled)
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte("hedged"))
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetHedgePolicy(HedgePolicy{Delay: 20 * time.Millisecond}).Build()
+
+	// Execution
+	start := time.Now()
+	resp, err := c.GET(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	// Validation
+	if string(body) != "hedged" {
+		t.Errorf("Invalid body %q", body)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("Hedged request took %v", elapsed)
+	}
+	select {
+	case <-loserCancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("Losing request was not cancelled")
+	}
+}
+
+func TestHedgingBudget(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetHedgePolicy(HedgePolicy{Delay: 5 * time.Millisecond, Budget: 0.5}).Build()
+
+	// Execution
+	for i := 0; i < 4; i++ {
+		resp, err := c.GET(server.URL, nil)
+		if err != nil {
+			t.Fatalf("Error executing GET: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// Validation
+	// 4 calls with a 50% budget allow 2 hedged requests
+	if hits := atomic.LoadInt32(&hits); hits != 6 {
+		t.Errorf("Server got %d requests", hits)
+	}
+}
+
+func TestHedgingPerRequestPolicy(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetHedgePolicy(HedgePolicy{Delay: 5 * time.Millisecond}).Build()
+
+	// Execution
+	resp, err := c.Do(WithHedgePolicy(context.Background(), HedgePolicy{}), http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = c.POST(server.URL, nil, "body")
+	if err != nil {
+		t.Fatalf("Error executing POST: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = c.Do(WithStreaming(context.Background()), http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Error executing streamed GET: %v", err)
+	}
+	resp.Body.Close()
+
+	// Validation
+	if hits := atomic.LoadInt32(&hits); hits != 3 {
+		t.Errorf("Requests should not be hedged, server got %d requests", hits)
+	}
+}
+
+func TestHedgingPercentileDelay(t *testing.T) {
+
+	// Initialization
+	h := newHedger()
+	policy := HedgePolicy{Delay: time.Second, Percentile: 0.9}
+
+	// Execution
+	_, okWithoutSamples := h.percentile(policy.Percentile)
+	for i := 1; i <= 100; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	delay, ok := h.delay(policy)
+
+	// Validation
+	if okWithoutSamples {
+		t.Error("Percentile should not be computed without enough samples")
+	}
+	if !ok || delay != 90*time.Millisecond {
+		t.Errorf("Invalid delay %v", delay)
+	}
+}
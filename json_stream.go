@@ -0,0 +1,186 @@
+package gohttpclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// ItemError is returned by JSONStream.Decode for an element that couldn't be
+// decoded. The stream can still be read past it.
+type ItemError struct {
+	// Index of the element in the stream, starting at 0.
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("unable to decode item %d. %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// JSONStream decodes the elements of a JSON stream one at a time, without reading
+// the whole body in memory. Streams can be newline-delimited JSON (NDJSON, JSON Lines)
+// or a top-level JSON array.
+//
+//	stream := NewJSONStream(response)
+//	defer stream.Close()
+//	for {
+//		var item Item
+//		err := stream.Decode(&item)
+//		if err == io.EOF {
+//			break
+//		}
+//		var itemErr *ItemError
+//		if errors.As(err, &itemErr) {
+//			continue
+//		}
+//		if err != nil {
+//			return err
+//		}
+//	}
+//
+// Huge bodies should be requested with WithStreaming, so the client total timeout
+// doesn't end them.
+type JSONStream struct {
+	body  io.ReadCloser
+	array bool
+
+	lines   *bufio.Reader
+	decoder *json.Decoder
+	started bool
+
+	index int
+	err   error
+}
+
+// NewNDJSONStream returns a JSONStream reading one JSON value per line from body.
+// Blank lines are skipped. A line with invalid JSON is an ItemError.
+func NewNDJSONStream(body io.ReadCloser) *JSONStream {
+	return &JSONStream{
+		body:  body,
+		lines: bufio.NewReader(body),
+	}
+}
+
+// NewJSONArrayStream returns a JSONStream reading the elements of the JSON array in body.
+// Since elements are not delimited, invalid JSON ends the stream.
+func NewJSONArrayStream(body io.ReadCloser) *JSONStream {
+	return &JSONStream{
+		body:    body,
+		array:   true,
+		decoder: json.NewDecoder(body),
+	}
+}
+
+// NewJSONStream returns a JSONStream reading the response body as NDJSON if its
+// content type is application/x-ndjson, application/ndjson or application/jsonl,
+// or as a JSON array otherwise.
+func NewJSONStream(response *http.Response) *JSONStream {
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return NewNDJSONStream(response.Body)
+	}
+	return NewJSONArrayStream(response.Body)
+}
+
+// Decode decodes the following element into v. It returns io.EOF once there are no
+// more elements, and an ItemError if the element couldn't be decoded into v.
+// Other errors end the stream. The body is closed once the stream ends.
+func (s *JSONStream) Decode(v interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	raw, err := s.next()
+	if err != nil {
+		s.fail(err)
+		return err
+	}
+
+	index := s.index
+	s.index++
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &ItemError{Index: index, Err: err}
+	}
+	return nil
+}
+
+// Close stops reading the stream, closing the body.
+func (s *JSONStream) Close() error {
+	if s.err == nil {
+		s.err = errors.New("stream closed")
+	}
+	return s.body.Close()
+}
+
+func (s *JSONStream) fail(err error) {
+	s.err = err
+	s.body.Close()
+}
+
+// next returns the following element, undecoded.
+func (s *JSONStream) next() (json.RawMessage, error) {
+	if s.array {
+		return s.nextArrayElement()
+	}
+	return s.nextLine()
+}
+
+func (s *JSONStream) nextLine() (json.RawMessage, error) {
+	for {
+		line, err := s.lines.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		// Invalid JSON fails when decoded, as an item error, since the following
+		// lines can still be read
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *JSONStream) nextArrayElement() (json.RawMessage, error) {
+	if !s.started {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, fmt.Errorf("unable to read JSON array. Unexpected %v", token)
+		}
+		s.started = true
+	}
+
+	if !s.decoder.More() {
+		if _, err := s.decoder.Token(); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return nil, io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := s.decoder.Decode(&raw); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return raw, nil
+}
+
+// unexpectedEOF tells the stream ended before the array did.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package gohttpclient
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type streamItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// closeRecorder records whether the body was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+// decodeAll decodes every item of the stream, collecting item errors.
+func decodeAll(stream *JSONStream) ([]streamItem, []int, error) {
+	var items []streamItem
+	var failed []int
+	for {
+		var item streamItem
+		err := stream.Decode(&item)
+		if err == io.EOF {
+			return items, failed, nil
+		}
+		var itemErr *ItemError
+		if errors.As(err, &itemErr) {
+			failed = append(failed, itemErr.Index)
+			continue
+		}
+		if err != nil {
+			return items, failed, err
+		}
+		items = append(items, item)
+	}
+}
+
+func TestNDJSONStream(t *testing.T) {
+
+	// Initialization
+	body := &closeRecorder{Reader: strings.NewReader("{\"id\":1,\"name\":\"a\"}\r\n\n{\"id\":\"two\"}\n{broken\n{\"id\":4,\"name\":\"d\"}")}
+	stream := NewNDJSONStream(body)
+
+	// Execution
+	items, failed, err := decodeAll(stream)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error decoding stream: %v", err)
+	}
+	if len(items) != 2 || items[0].Name != "a" || items[1].ID != 4 {
+		t.Errorf("Invalid items %+v", items)
+	}
+	if len(failed) != 2 || failed[0] != 1 || failed[1] != 2 {
+		t.Errorf("Invalid failed items %v", failed)
+	}
+	if !body.closed {
+		t.Error("Body should be closed once the stream ends")
+	}
+}
+
+func TestJSONArrayStream(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(` [ {"id": 1, "name": "a"}, {"id": "two"}, {"id": 3, "name": "c"} ] `))
+	}))
+	defer server.Close()
+
+	resp, err := NewBuilder().Build().GET(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+
+	// Execution
+	items, failed, err := decodeAll(NewJSONStream(resp))
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error decoding stream: %v", err)
+	}
+	if len(items) != 2 || items[1].Name != "c" {
+		t.Errorf("Invalid items %+v", items)
+	}
+	if len(failed) != 1 || failed[0] != 1 {
+		t.Errorf("Invalid failed items %v", failed)
+	}
+}
+
+func TestJSONArrayStreamErrors(t *testing.T) {
+
+	// Initialization
+	truncated := NewJSONArrayStream(ioutil.NopCloser(strings.NewReader(`[{"id": 1}, {"id": 2}`)))
+	notArray := NewJSONArrayStream(ioutil.NopCloser(strings.NewReader(`{"id": 1}`)))
+
+	// Execution
+	items, _, truncatedErr := decodeAll(truncated)
+	_, _, notArrayErr := decodeAll(notArray)
+
+	// Validation
+	if truncatedErr == nil || truncatedErr == io.EOF || len(items) != 2 {
+		t.Errorf("Truncated array should fail after 2 items, got %v after %d", truncatedErr, len(items))
+	}
+	if notArrayErr == nil {
+		t.Error("Error expected for a body that is not an array")
+	}
+}
+
+func TestJSONStreamClose(t *testing.T) {
+
+	// Initialization
+	body := &closeRecorder{Reader: strings.NewReader("{\"id\":1}\n{\"id\":2}\n")}
+	stream := NewJSONStream(&http.Response{
+		Header: http.Header{"Content-Type": []string{"application/x-ndjson"}},
+		Body:   body,
+	})
+
+	// Execution
+	var item streamItem
+	firstErr := stream.Decode(&item)
+	stream.Close()
+	afterCloseErr := stream.Decode(&item)
+
+	// Validation
+	if firstErr != nil || item.ID != 1 {
+		t.Errorf("Invalid first item %+v, %v", item, firstErr)
+	}
+	if !body.closed || afterCloseErr == nil {
+		t.Error("Stream should stop once closed")
+	}
+}
@@ -0,0 +1,259 @@
+package gohttpclient
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogLevel sets how much of every request is logged.
+type LogLevel int
+
+const (
+	// LogOff disables request logging.
+	LogOff LogLevel = iota
+	// LogBasic logs method, URL, status, duration, sizes and attempt.
+	LogBasic
+	// LogHeaders adds request and response headers to LogBasic.
+	LogHeaders
+	// LogBodies adds request and response bodies, truncated to the body limit, to LogHeaders.
+	LogBodies
+)
+
+// LogEntry is the structured log of a request sent by the client.
+// Headers, URL and bodies are redacted according to the client RedactionPolicy.
+type LogEntry struct {
+	Time time.Time
+
+	Method string
+	URL    string
+	// Route is the unexpanded route set with WithRouteTemplate, empty if none.
+	Route   string
+	Attempt int
+
+	// StatusCode is zero when no response was received.
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+
+	RequestSize int64
+	// ResponseSize is -1 when the response length is unknown.
+	ResponseSize int64
+
+	// Set from LogHeaders level on.
+	RequestHeaders  http.Header
+	ResponseHeaders http.Header
+
+	// Set on LogBodies level.
+	RequestBody  string
+	ResponseBody string
+
+	// Curl reproduces the request. It's set for failed requests,
+	// when enabled with LogCurlOnFailure.
+	Curl string
+}
+
+// RequestLogger receives the log entry of every request sent by the client.
+// Implementations must be safe for concurrent use by multiple goroutines.
+type RequestLogger interface {
+	LogRequest(entry LogEntry)
+}
+
+// RequestLoggerFunc adapts a function to a RequestLogger.
+type RequestLoggerFunc func(entry LogEntry)
+
+func (f RequestLoggerFunc) LogRequest(entry LogEntry) {
+	f(entry)
+}
+
+// NewStdLogger returns a RequestLogger writing every entry to logger
+// as a single line of key=value pairs.
+func NewStdLogger(logger *log.Logger) RequestLogger {
+	return RequestLoggerFunc(func(entry LogEntry) {
+		logger.Println(entry.String())
+	})
+}
+
+// String formats the entry as key=value pairs.
+func (e LogEntry) String() string {
+	var builder strings.Builder
+
+	writeLogField(&builder, "method", e.Method)
+	writeLogField(&builder, "url", e.URL)
+	if e.Route != "" {
+		writeLogField(&builder, "route", e.Route)
+	}
+	writeLogField(&builder, "attempt", strconv.Itoa(e.Attempt))
+	if e.StatusCode != 0 {
+		writeLogField(&builder, "status", strconv.Itoa(e.StatusCode))
+	}
+	writeLogField(&builder, "duration", e.Duration.String())
+	writeLogField(&builder, "request_size", strconv.FormatInt(e.RequestSize, 10))
+	writeLogField(&builder, "response_size", strconv.FormatInt(e.ResponseSize, 10))
+	if e.Err != nil {
+		writeLogField(&builder, "error", e.Err.Error())
+	}
+	if e.RequestHeaders != nil {
+		writeLogField(&builder, "request_headers", formatLogHeaders(e.RequestHeaders))
+	}
+	if e.ResponseHeaders != nil {
+		writeLogField(&builder, "response_headers", formatLogHeaders(e.ResponseHeaders))
+	}
+	if e.RequestBody != "" {
+		writeLogField(&builder, "request_body", e.RequestBody)
+	}
+	if e.ResponseBody != "" {
+		writeLogField(&builder, "response_body", e.ResponseBody)
+	}
+	if e.Curl != "" {
+		writeLogField(&builder, "curl", e.Curl)
+	}
+	return builder.String()
+}
+
+func writeLogField(builder *strings.Builder, key, value string) {
+	if builder.Len() > 0 {
+		builder.WriteByte(' ')
+	}
+	builder.WriteString(key)
+	builder.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+		builder.WriteString(strconv.Quote(value))
+		return
+	}
+	builder.WriteString(value)
+}
+
+func formatLogHeaders(headers http.Header) string {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	formatted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, key+": "+strings.Join(headers[key], ", "))
+	}
+	return strings.Join(formatted, "; ")
+}
+
+// logRequest logs a request once its response headers are read or it failed.
+// On LogBodies level the beginning of the response body is read, up to the body limit,
+// and put back so the caller still reads the full body. Streamed response bodies aren't read.
+func (c *client) logRequest(request *http.Request, response *http.Response, err error, duration time.Duration, curl string) {
+	logger := c.builder.logger
+	level := c.builder.logLevel
+	if logger == nil || level <= LogOff {
+		return
+	}
+	policy := c.builder.redactionPolicy
+
+	entry := LogEntry{
+		Time:         time.Now(),
+		Method:       request.Method,
+		URL:          policy.RedactURL(request.URL),
+		Route:        routeTemplateFromContext(request.Context()),
+		Attempt:      attemptFromContext(request.Context()),
+		Duration:     duration,
+		Err:          err,
+		RequestSize:  request.ContentLength,
+		ResponseSize: -1,
+		Curl:         curl,
+	}
+	if response != nil {
+		entry.StatusCode = response.StatusCode
+		entry.ResponseSize = response.ContentLength
+	}
+
+	if level >= LogHeaders {
+		entry.RequestHeaders = policy.RedactHeaders(request.Header)
+		if response != nil {
+			entry.ResponseHeaders = policy.RedactHeaders(response.Header)
+		}
+	}
+
+	if level >= LogBodies {
+		entry.RequestBody = c.logRequestBody(request)
+		if response != nil {
+			entry.ResponseBody = c.logResponseBody(request, response)
+		}
+	}
+
+	logger.LogRequest(entry)
+}
+
+func (c *client) logRequestBody(request *http.Request) string {
+	if request.GetBody == nil || request.ContentLength == 0 {
+		return ""
+	}
+	body, err := request.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	// Request bodies are already in memory, so they're read in full to redact them.
+	bodyBytes, err := ioutil.ReadAll(body)
+	if err != nil {
+		return ""
+	}
+	return c.formatLogBody(bodyBytes, true)
+}
+
+func (c *client) logResponseBody(request *http.Request, response *http.Response) string {
+	if response.Body == nil || response.Body == http.NoBody {
+		return ""
+	}
+	// Streamed bodies may never end or be huge, so they aren't awaited.
+	if isStreaming(request.Context()) || strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream") {
+		return ""
+	}
+
+	limit := c.logBodyLimit()
+	prefix, err := ioutil.ReadAll(io.LimitReader(response.Body, int64(limit)+1))
+	response.Body = &prefixedReadCloser{
+		Reader: io.MultiReader(bytes.NewReader(prefix), response.Body),
+		Closer: response.Body,
+	}
+	if err != nil {
+		return ""
+	}
+	return c.formatLogBody(prefix, len(prefix) <= limit)
+}
+
+// formatLogBody redacts and truncates a body to be logged.
+// Incomplete bodies can't be parsed to redact their JSON fields, so they
+// aren't logged at all if the policy has any.
+func (c *client) formatLogBody(body []byte, complete bool) string {
+	policy := c.builder.redactionPolicy
+	if !complete && len(policy.JSONFields) > 0 {
+		return "[body exceeds the log limit and can't be redacted]"
+	}
+	body = policy.RedactJSON(body)
+
+	limit := c.logBodyLimit()
+	if len(body) > limit {
+		return string(body[:limit]) + "...[truncated]"
+	}
+	return string(body)
+}
+
+func (c *client) logBodyLimit() int {
+	if c.builder.logBodyLimit <= 0 {
+		return defaultLogBodyLimit
+	}
+	return c.builder.logBodyLimit
+}
+
+// prefixedReadCloser reads again the bytes consumed from a body before reading the rest of it.
+type prefixedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,49 @@
+package gohttpclient
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MetricLabels identify the series a request is reported into.
+type MetricLabels struct {
+	Method string
+	Host   string
+	// Route is the unexpanded route set with WithRouteTemplate, empty if none.
+	Route string
+	// StatusClass is the response status class, like "2xx" or "5xx",
+	// or "error" when no response was received. It's empty when a request starts.
+	StatusClass string
+}
+
+// MetricsRecorder receives every request sent by the client.
+// Implementations must be safe for concurrent use by multiple goroutines.
+type MetricsRecorder interface {
+	// RequestStarted is called right before a request is sent.
+	RequestStarted(labels MetricLabels)
+
+	// RequestFinished is called once response headers are read or the request failed.
+	// err is the transport error, if any; HTTP error statuses are reported through
+	// labels.StatusClass.
+	RequestFinished(labels MetricLabels, duration time.Duration, err error)
+}
+
+func newMetricLabels(request *http.Request) MetricLabels {
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	return MetricLabels{
+		Method: request.Method,
+		Host:   host,
+		Route:  routeTemplateFromContext(request.Context()),
+	}
+}
+
+func statusClass(response *http.Response, err error) string {
+	if err != nil || response == nil {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", response.StatusCode/100)
+}
@@ -0,0 +1,223 @@
+package gohttpclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsNamespace = "gohttpclient"
+
+// DefaultLatencyBuckets are the request duration histogram upper bounds, in seconds,
+// used when none are given to NewInMemoryMetrics.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// InMemoryMetrics is a dependency free MetricsRecorder keeping request counts,
+// latency histograms, in-flight gauges and error counts in memory.
+// It's an http.Handler rendering them in the Prometheus text exposition format,
+// so it can be scraped directly.
+type InMemoryMetrics struct {
+	mutex sync.Mutex
+
+	buckets []float64
+
+	requests  map[MetricLabels]uint64
+	durations map[MetricLabels]*latencyHistogram
+	inFlight  map[MetricLabels]int64
+	errors    map[MetricLabels]uint64
+}
+
+type latencyHistogram struct {
+	counts []uint64 // one per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewInMemoryMetrics returns an empty InMemoryMetrics with the given latency bucket
+// upper bounds, in seconds. DefaultLatencyBuckets are used if none are given.
+// A +Inf bound is always rendered, so it's dropped from buckets.
+func NewInMemoryMetrics(buckets ...float64) *InMemoryMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	sortedBuckets := make([]float64, 0, len(buckets))
+	for _, bucket := range buckets {
+		if !math.IsInf(bucket, 1) {
+			sortedBuckets = append(sortedBuckets, bucket)
+		}
+	}
+	sort.Float64s(sortedBuckets)
+
+	return &InMemoryMetrics{
+		buckets:   sortedBuckets,
+		requests:  make(map[MetricLabels]uint64),
+		durations: make(map[MetricLabels]*latencyHistogram),
+		inFlight:  make(map[MetricLabels]int64),
+		errors:    make(map[MetricLabels]uint64),
+	}
+}
+
+func (m *InMemoryMetrics) RequestStarted(labels MetricLabels) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	labels.StatusClass = ""
+	m.inFlight[labels]++
+}
+
+func (m *InMemoryMetrics) RequestFinished(labels MetricLabels, duration time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	startedLabels := labels
+	startedLabels.StatusClass = ""
+	m.inFlight[startedLabels]--
+	if err != nil {
+		m.errors[startedLabels]++
+	}
+
+	m.requests[labels]++
+
+	histogram, ok := m.durations[labels]
+	if !ok {
+		histogram = &latencyHistogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[labels] = histogram
+	}
+	seconds := duration.Seconds()
+	for i, upperBound := range m.buckets {
+		if seconds <= upperBound {
+			histogram.counts[i]++
+			break
+		}
+	}
+	histogram.sum += seconds
+	histogram.count++
+}
+
+// ServeHTTP renders the metrics in the Prometheus text exposition format.
+func (m *InMemoryMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (m *InMemoryMetrics) WritePrometheus(w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	buf := bufio.NewWriter(w)
+
+	name := metricsNamespace + "_requests_total"
+	writeMetricHeader(buf, name, "counter", "Total number of HTTP requests sent.")
+	for _, labels := range sortedMetricLabels(m.requests) {
+		fmt.Fprintf(buf, "%s%s %d\n", name, formatMetricLabels(labels, true), m.requests[labels])
+	}
+
+	name = metricsNamespace + "_request_duration_seconds"
+	writeMetricHeader(buf, name, "histogram", "Time from sending a request until its response headers are read.")
+	for _, labels := range sortedMetricLabels(m.durations) {
+		histogram := m.durations[labels]
+		var cumulative uint64
+		for i, upperBound := range m.buckets {
+			cumulative += histogram.counts[i]
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", name,
+				formatMetricLabels(labels, true, "le", formatFloat(upperBound)), cumulative)
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", name, formatMetricLabels(labels, true, "le", "+Inf"), histogram.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", name, formatMetricLabels(labels, true), formatFloat(histogram.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", name, formatMetricLabels(labels, true), histogram.count)
+	}
+
+	name = metricsNamespace + "_requests_in_flight"
+	writeMetricHeader(buf, name, "gauge", "Number of HTTP requests waiting for a response.")
+	for _, labels := range sortedMetricLabels(m.inFlight) {
+		fmt.Fprintf(buf, "%s%s %d\n", name, formatMetricLabels(labels, false), m.inFlight[labels])
+	}
+
+	name = metricsNamespace + "_request_errors_total"
+	writeMetricHeader(buf, name, "counter", "Total number of HTTP requests that failed without a response.")
+	for _, labels := range sortedMetricLabels(m.errors) {
+		fmt.Fprintf(buf, "%s%s %d\n", name, formatMetricLabels(labels, false), m.errors[labels])
+	}
+
+	return buf.Flush()
+}
+
+func writeMetricHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+// sortedMetricLabels returns the keys of a series map in a stable order.
+// series must be a map keyed by MetricLabels.
+func sortedMetricLabels(series interface{}) []MetricLabels {
+	var labels []MetricLabels
+	switch typedSeries := series.(type) {
+	case map[MetricLabels]uint64:
+		for key := range typedSeries {
+			labels = append(labels, key)
+		}
+	case map[MetricLabels]int64:
+		for key := range typedSeries {
+			labels = append(labels, key)
+		}
+	case map[MetricLabels]*latencyHistogram:
+		for key := range typedSeries {
+			labels = append(labels, key)
+		}
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		return a.StatusClass < b.StatusClass
+	})
+	return labels
+}
+
+// formatMetricLabels renders labels as {method="GET",...}, followed by any extra name/value pairs.
+func formatMetricLabels(labels MetricLabels, withStatusClass bool, extra ...string) string {
+	pairs := []string{
+		"method", labels.Method,
+		"host", labels.Host,
+		"route", labels.Route,
+	}
+	if withStatusClass {
+		pairs = append(pairs, "status_class", labels.StatusClass)
+	}
+	pairs = append(pairs, extra...)
+
+	formatted := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		formatted = append(formatted, pairs[i]+`="`+escapeLabelValue(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(formatted, ",") + "}"
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
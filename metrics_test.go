@@ -0,0 +1,134 @@
+package gohttpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInMemoryMetricsPrometheusFormat(t *testing.T) {
+
+	// Initialization
+	metrics := NewInMemoryMetrics(0.1, 1)
+	labels := MetricLabels{Method: http.MethodGet, Host: "api.example.com", Route: `/users/{id}`}
+
+	// Execution
+	metrics.RequestStarted(labels)
+	labels.StatusClass = "2xx"
+	metrics.RequestFinished(labels, 50*time.Millisecond, nil)
+
+	labels.StatusClass = ""
+	metrics.RequestStarted(labels)
+	labels.StatusClass = "error"
+	metrics.RequestFinished(labels, 2*time.Second, context.DeadlineExceeded)
+
+	labels.StatusClass = ""
+	metrics.RequestStarted(labels)
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Validation
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Invalid content type %s", recorder.Header().Get("Content-Type"))
+	}
+
+	output := recorder.Body.String()
+	expectedLines := []string{
+		`# TYPE gohttpclient_requests_total counter`,
+		`gohttpclient_requests_total{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx"} 1`,
+		`gohttpclient_requests_total{method="GET",host="api.example.com",route="/users/{id}",status_class="error"} 1`,
+		`# TYPE gohttpclient_request_duration_seconds histogram`,
+		`gohttpclient_request_duration_seconds_bucket{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx",le="0.1"} 1`,
+		`gohttpclient_request_duration_seconds_bucket{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx",le="1"} 1`,
+		`gohttpclient_request_duration_seconds_bucket{method="GET",host="api.example.com",route="/users/{id}",status_class="error",le="1"} 0`,
+		`gohttpclient_request_duration_seconds_bucket{method="GET",host="api.example.com",route="/users/{id}",status_class="error",le="+Inf"} 1`,
+		`gohttpclient_request_duration_seconds_sum{method="GET",host="api.example.com",route="/users/{id}",status_class="error"} 2`,
+		`gohttpclient_request_duration_seconds_count{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx"} 1`,
+		`# TYPE gohttpclient_requests_in_flight gauge`,
+		`gohttpclient_requests_in_flight{method="GET",host="api.example.com",route="/users/{id}"} 1`,
+		`# TYPE gohttpclient_request_errors_total counter`,
+		`gohttpclient_request_errors_total{method="GET",host="api.example.com",route="/users/{id}"} 1`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Missing line %s in output:\n%s", line, output)
+		}
+	}
+}
+
+func TestInMemoryMetricsInfBucket(t *testing.T) {
+
+	// Initialization
+	metrics := NewInMemoryMetrics(1, math.Inf(1))
+	labels := MetricLabels{Method: http.MethodGet, Host: "api.example.com", StatusClass: "2xx"}
+	metrics.RequestFinished(labels, time.Millisecond, nil)
+
+	// Execution
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Validation
+	if count := strings.Count(recorder.Body.String(), `le="+Inf"`); count != 1 {
+		t.Errorf("A single +Inf bucket expected, got %d", count)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if escaped := escapeLabelValue("a\"b\\c\nd"); escaped != `a\"b\\c\nd` {
+		t.Errorf("Invalid escaped value %s", escaped)
+	}
+}
+
+func TestClientReportsMetrics(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewInMemoryMetrics()
+	c := NewBuilder().SetMetricsRecorder(metrics).Build()
+
+	// Execution
+	ctx := WithRouteTemplate(context.Background(), "/items/{id}")
+	for _, path := range []string{"/items/1", "/items/2"} {
+		resp, err := c.Do(ctx, http.MethodGet, server.URL+path, nil, nil)
+		if err != nil {
+			t.Fatalf("Error executing GET: %v", err)
+		}
+		resp.Body.Close()
+	}
+	resp, err := c.GET(server.URL+"/missing", nil)
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+
+	// Validation
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+	output := string(body)
+
+	serverURL, _ := url.Parse(server.URL)
+	expectedLines := []string{
+		`gohttpclient_requests_total{method="GET",host="` + serverURL.Host + `",route="/items/{id}",status_class="2xx"} 2`,
+		`gohttpclient_requests_total{method="GET",host="` + serverURL.Host + `",route="",status_class="4xx"} 1`,
+		`gohttpclient_requests_in_flight{method="GET",host="` + serverURL.Host + `",route="/items/{id}"} 0`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Missing line %s in output:\n%s", line, output)
+		}
+	}
+}
@@ -10,15 +10,19 @@ type httpClientMock struct{}
 
 func (c *httpClientMock) Do(request *http.Request) (*http.Response, error) {
 
-	requestBody, err := request.GetBody()
-	if err != nil {
-		return nil, err
-	}
-	defer requestBody.Close()
+	var body []byte
+	// Requests without body, like health checks, don't have GetBody
+	if request.GetBody != nil {
+		requestBody, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer requestBody.Close()
 
-	body, err := ioutil.ReadAll(requestBody)
-	if err != nil {
-		return nil, err
+		body, err = ioutil.ReadAll(requestBody)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if mock := MockupServer.getMock(request.Method, request.URL.String(), string(body)); mock != nil {
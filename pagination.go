@@ -0,0 +1,373 @@
+package gohttpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Page is a page of results fetched by a Paginator.
+type Page struct {
+	// URL the page was fetched from.
+	URL string
+
+	// Response with the page. Its body is already read into Body.
+	Response *http.Response
+	Body     []byte
+
+	// Items of the page, taken from the Paginator ItemsField.
+	Items []json.RawMessage
+}
+
+// PaginationStrategy tells where the page following another one is.
+type PaginationStrategy interface {
+	// Next returns the URL of the page following page, or false if page is the last one.
+	Next(page *Page) (string, bool, error)
+}
+
+// LinkHeaderPagination follows the URL in the Link header with rel="next", as
+// described in RFC 8288, like the GitHub API does.
+type LinkHeaderPagination struct{}
+
+func (LinkHeaderPagination) Next(page *Page) (string, bool, error) {
+	next, ok := findLink(page.Response.Header.Values("Link"), "next")
+	if !ok {
+		return "", false, nil
+	}
+	return resolveReference(page.URL, next)
+}
+
+// CursorPagination takes the cursor of the following page from a field of the JSON
+// body, like "meta.next_cursor", and sends it in a query parameter, like "cursor".
+// The last page has no cursor, or an empty one.
+type CursorPagination struct {
+	Field string
+	Param string
+}
+
+func (s CursorPagination) Next(page *Page) (string, bool, error) {
+	raw, ok := lookupJSONField(page.Body, s.Field)
+	if !ok {
+		return "", false, nil
+	}
+	var cursor interface{}
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return "", false, fmt.Errorf("unable to read cursor. %v", err)
+	}
+
+	var value string
+	switch c := cursor.(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		value = c
+	case float64:
+		value = strconv.FormatFloat(c, 'f', -1, 64)
+	default:
+		return "", false, fmt.Errorf("unable to read cursor. Unsupported value %s", raw)
+	}
+	if value == "" {
+		return "", false, nil
+	}
+	return setQueryParam(page.URL, s.Param, value)
+}
+
+// OffsetPagination increases a page number or offset query parameter, like "page"
+// or "offset", by Step on every page. The last page has no items, or fewer than Limit.
+type OffsetPagination struct {
+	Param string
+
+	// Step the parameter is increased by, like 1 for page numbers or the page size
+	// for offsets. Default is 1.
+	Step int
+
+	// Start is the value of the parameter when the first URL lacks it.
+	Start int
+
+	// Limit is the number of items in full pages. Zero means only an empty page
+	// is the last one.
+	Limit int
+}
+
+func (s OffsetPagination) Next(page *Page) (string, bool, error) {
+	if len(page.Items) == 0 || (s.Limit > 0 && len(page.Items) < s.Limit) {
+		return "", false, nil
+	}
+
+	parsed, err := url.Parse(page.URL)
+	if err != nil {
+		return "", false, err
+	}
+	current := s.Start
+	if value := parsed.Query().Get(s.Param); value != "" {
+		current, err = strconv.Atoi(value)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid %s parameter %q", s.Param, value)
+		}
+	}
+	step := s.Step
+	if step <= 0 {
+		step = 1
+	}
+	return setQueryParam(page.URL, s.Param, strconv.Itoa(current+step))
+}
+
+// Paginator fetches pages lazily, one per call to Next or when NextItem runs out
+// of items, until the strategy finds no following page, a limit is reached,
+// or the context is cancelled.
+//
+//	paginator := NewPaginator(ctx, client, "https://api.github.com/orgs/golang/repos", nil, LinkHeaderPagination{})
+//	var repo Repository
+//	for paginator.NextItem(&repo) {
+//		...
+//	}
+//	if err := paginator.Err(); err != nil {
+//		...
+//	}
+type Paginator struct {
+	// ItemsField is the field of the JSON body with the items of a page, like "data".
+	// Empty means the body is the array of items.
+	ItemsField string
+
+	// MaxPages is the maximum number of pages fetched. Zero means no limit.
+	MaxPages int
+
+	// MaxItems is the maximum number of items returned. Zero means no limit.
+	MaxItems int
+
+	ctx      context.Context
+	client   Doer
+	headers  http.Header
+	strategy PaginationStrategy
+
+	nextURL string
+	done    bool
+	page    *Page
+	err     error
+
+	pages   int
+	items   int
+	pending []json.RawMessage
+}
+
+// NewPaginator returns a Paginator starting with a GET request to url.
+func NewPaginator(ctx context.Context, client Doer, url string, headers http.Header, strategy PaginationStrategy) *Paginator {
+	return &Paginator{
+		ctx:      ctx,
+		client:   client,
+		headers:  headers,
+		strategy: strategy,
+		nextURL:  url,
+	}
+}
+
+// Next fetches the following page, returning false when there are no more pages
+// or it failed, which Err tells.
+func (p *Paginator) Next() bool {
+	if p.done {
+		return false
+	}
+	if p.MaxPages > 0 && p.pages >= p.MaxPages {
+		return p.finish(nil)
+	}
+	if p.MaxItems > 0 && p.items >= p.MaxItems {
+		return p.finish(nil)
+	}
+	if err := p.ctx.Err(); err != nil {
+		return p.finish(err)
+	}
+
+	page, err := p.fetch(p.nextURL)
+	if err != nil {
+		// A page whose items couldn't be read is still available with Page
+		p.page = page
+		return p.finish(err)
+	}
+	p.pages++
+	p.page = page
+
+	if p.MaxItems > 0 && p.items+len(page.Items) > p.MaxItems {
+		page.Items = page.Items[:p.MaxItems-p.items]
+	}
+	p.items += len(page.Items)
+
+	next, ok, err := p.strategy.Next(page)
+	if err != nil {
+		return p.finish(err)
+	}
+	if !ok {
+		// The page is returned, but it's the last one
+		p.done = true
+	}
+	p.nextURL = next
+	return true
+}
+
+// Page returns the page fetched by the last call to Next, even if its items
+// couldn't be read.
+func (p *Paginator) Page() *Page {
+	return p.page
+}
+
+// NextItem decodes the following item into v, fetching pages as needed.
+// It returns false when there are no more items or it failed, which Err tells.
+func (p *Paginator) NextItem(v interface{}) bool {
+	for len(p.pending) == 0 {
+		if !p.Next() {
+			return false
+		}
+		p.pending = p.page.Items
+	}
+
+	item := p.pending[0]
+	p.pending = p.pending[1:]
+	if err := json.Unmarshal(item, v); err != nil {
+		p.finish(fmt.Errorf("unable to unmarshal item. %v", err))
+		return false
+	}
+	return true
+}
+
+// Err returns the error that stopped the pagination, if any.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+func (p *Paginator) finish(err error) bool {
+	p.done = true
+	p.err = err
+	p.pending = nil
+	return false
+}
+
+func (p *Paginator) fetch(pageURL string) (*Page, error) {
+	response, err := p.client.Do(p.ctx, http.MethodGet, pageURL, p.headers, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read page %s. %v", pageURL, err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("unable to get page %s. Status %d", pageURL, response.StatusCode)
+	}
+
+	page := &Page{URL: pageURL, Response: response, Body: body}
+	// Pages without the items field just have no items
+	if raw, ok := lookupJSONField(body, p.ItemsField); ok {
+		if err := json.Unmarshal(raw, &page.Items); err != nil {
+			return page, fmt.Errorf("unable to read items of page %s. %v", pageURL, err)
+		}
+	}
+	return page, nil
+}
+
+// findLink returns the target of the link with the relation in Link header values,
+// like <https://api.example.com/items?page=2>; rel="next".
+func findLink(values []string, relation string) (string, bool) {
+	for _, value := range values {
+		for _, link := range splitLinks(value) {
+			start := strings.IndexByte(link, '<')
+			end := strings.IndexByte(link, '>')
+			if start < 0 || end < start {
+				continue
+			}
+			target := link[start+1 : end]
+
+			for _, param := range strings.Split(link[end+1:], ";") {
+				name, paramValue := splitParam(param)
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				// rel may have several space separated relations
+				for _, rel := range strings.Fields(paramValue) {
+					if strings.EqualFold(rel, relation) {
+						return target, true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// splitLinks splits a Link header value by the commas outside of URLs and quotes.
+func splitLinks(value string) []string {
+	var links []string
+	inURL, inQuotes, start := false, false, 0
+	for i, r := range value {
+		switch {
+		case r == '<' && !inQuotes:
+			inURL = true
+		case r == '>' && !inQuotes:
+			inURL = false
+		case r == '"' && !inURL:
+			inQuotes = !inQuotes
+		case r == ',' && !inURL && !inQuotes:
+			links = append(links, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(links, value[start:])
+}
+
+func splitParam(param string) (string, string) {
+	parts := strings.SplitN(param, "=", 2)
+	name := strings.TrimSpace(parts[0])
+	if len(parts) == 1 {
+		return name, ""
+	}
+	return name, strings.Trim(strings.TrimSpace(parts[1]), `"`)
+}
+
+func resolveReference(base string, reference string) (string, bool, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", false, err
+	}
+	referenceURL, err := url.Parse(reference)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid next page URL %s. %v", reference, err)
+	}
+	return baseURL.ResolveReference(referenceURL).String(), true, nil
+}
+
+func setQueryParam(rawURL string, param string, value string) (string, bool, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false, err
+	}
+	query := parsed.Query()
+	query.Set(param, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), true, nil
+}
+
+// lookupJSONField returns the value at a dot separated path of a JSON object,
+// like "meta.next_cursor", or the whole body if the path is empty.
+func lookupJSONField(body []byte, path string) (json.RawMessage, bool) {
+	current := json.RawMessage(body)
+	if path == "" {
+		return current, len(body) > 0
+	}
+	for _, field := range strings.Split(path, ".") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(current, &object); err != nil {
+			return nil, false
+		}
+		value, ok := object[field]
+		if !ok {
+			return nil, false
+		}
+		current = value
+	}
+	return current, true
+}
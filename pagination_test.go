@@ -0,0 +1,199 @@
+package gohttpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// newItemsServer serves items 1 to total, pageSize per page, taking the page number
+// from the "page" query parameter.
+func newItemsServer(total int, pageSize int, respond func(w http.ResponseWriter, r *http.Request, page int, items []int)) (*httptest.Server, *int32) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		items := []int{}
+		for i := (page-1)*pageSize + 1; i <= page*pageSize && i <= total; i++ {
+			items = append(items, i)
+		}
+		respond(w, r, page, items)
+	}))
+	return server, &hits
+}
+
+func collectItems(t *testing.T, paginator *Paginator) []int {
+	t.Helper()
+	var items []int
+	var item int
+	for paginator.NextItem(&item) {
+		items = append(items, item)
+	}
+	if err := paginator.Err(); err != nil {
+		t.Fatalf("Error paginating: %v", err)
+	}
+	return items
+}
+
+func TestLinkHeaderPagination(t *testing.T) {
+
+	// Initialization
+	server, hits := newItemsServer(5, 2, func(w http.ResponseWriter, r *http.Request, page int, items []int) {
+		if page*2 < 5 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=3>; rel="last"`, page+1))
+		}
+		json.NewEncoder(w).Encode(items)
+	})
+	defer server.Close()
+
+	paginator := NewPaginator(context.Background(), NewBuilder().Build(), server.URL+"/items", nil, LinkHeaderPagination{})
+
+	// Execution
+	items := collectItems(t, paginator)
+
+	// Validation
+	if fmt.Sprint(items) != "[1 2 3 4 5]" {
+		t.Errorf("Invalid items %v", items)
+	}
+	if atomic.LoadInt32(hits) != 3 {
+		t.Errorf("Server got %d requests", *hits)
+	}
+}
+
+func TestCursorPaginationMaxItems(t *testing.T) {
+
+	// Initialization
+	server, hits := newItemsServer(10, 3, func(w http.ResponseWriter, r *http.Request, page int, items []int) {
+		cursor := ""
+		if page*3 < 10 {
+			cursor = strconv.Itoa(page + 1)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": items,
+			"meta": map[string]string{"next": cursor},
+		})
+	})
+	defer server.Close()
+
+	paginator := NewPaginator(context.Background(), NewBuilder().Build(), server.URL, nil, CursorPagination{Field: "meta.next", Param: "page"})
+	paginator.ItemsField = "data"
+	paginator.MaxItems = 4
+
+	// Execution
+	items := collectItems(t, paginator)
+
+	// Validation
+	if fmt.Sprint(items) != "[1 2 3 4]" {
+		t.Errorf("Invalid items %v", items)
+	}
+	if atomic.LoadInt32(hits) != 2 {
+		t.Errorf("Pages should be fetched lazily, server got %d requests", *hits)
+	}
+}
+
+func TestOffsetPaginationPages(t *testing.T) {
+
+	// Initialization
+	server, _ := newItemsServer(7, 3, func(w http.ResponseWriter, r *http.Request, page int, items []int) {
+		json.NewEncoder(w).Encode(items)
+	})
+	defer server.Close()
+
+	paginator := NewPaginator(context.Background(), NewBuilder().Build(), server.URL+"?page=1", nil, OffsetPagination{Param: "page", Limit: 3})
+
+	// Execution
+	var pages []string
+	for paginator.Next() {
+		pages = append(pages, string(paginator.Page().Body))
+	}
+
+	// Validation
+	if paginator.Err() != nil {
+		t.Fatalf("Error paginating: %v", paginator.Err())
+	}
+	if len(pages) != 3 || pages[2] != "[7]\n" {
+		t.Errorf("Invalid pages %q", pages)
+	}
+}
+
+func TestPaginationCancellation(t *testing.T) {
+
+	// Initialization
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, hits := newItemsServer(100, 1, func(w http.ResponseWriter, r *http.Request, page int, items []int) {
+		json.NewEncoder(w).Encode(items)
+	})
+	defer server.Close()
+
+	paginator := NewPaginator(ctx, NewBuilder().Build(), server.URL, nil, OffsetPagination{Param: "page", Start: 1})
+
+	// Execution
+	pages := 0
+	for paginator.Next() {
+		pages++
+		if pages == 2 {
+			cancel()
+		}
+	}
+
+	// Validation
+	if paginator.Err() != context.Canceled {
+		t.Errorf("Cancellation error expected, got %v", paginator.Err())
+	}
+	if atomic.LoadInt32(hits) != 2 || pages != 2 {
+		t.Errorf("Pagination should stop once cancelled, got %d pages and %d requests", pages, *hits)
+	}
+}
+
+func TestPaginationInvalidItems(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"id": 1}}`))
+	}))
+	defer server.Close()
+
+	paginator := NewPaginator(context.Background(), NewBuilder().Build(), server.URL, nil, LinkHeaderPagination{})
+	paginator.ItemsField = "data"
+
+	// Execution
+	ok := paginator.Next()
+
+	// Validation
+	if ok || paginator.Err() == nil {
+		t.Fatal("Items that are not an array should be an error")
+	}
+	if page := paginator.Page(); page == nil || string(page.Body) != `{"data": {"id": 1}}` {
+		t.Errorf("Page should be returned with the error, got %+v", page)
+	}
+}
+
+func TestFindLink(t *testing.T) {
+
+	// Initialization
+	values := []string{
+		`<https://example.com/a?x=1,2>; title="first, page"; rel="prev"`,
+		`<https://example.com/b>; rel="last next"`,
+	}
+
+	// Execution
+	next, ok := findLink(values, "next")
+	_, okMissing := findLink(values, "first")
+
+	// Validation
+	if !ok || next != "https://example.com/b" {
+		t.Errorf("Invalid next link %q", next)
+	}
+	if okMissing {
+		t.Error("Missing relation should not be found")
+	}
+}
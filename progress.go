@@ -0,0 +1,146 @@
+package gohttpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultProgressInterval time.Duration = 100 * time.Millisecond
+
+// Progress is the state of a transfer.
+type Progress struct {
+	// Transferred is the number of bytes transferred so far.
+	Transferred int64
+
+	// Total is the number of bytes to transfer, -1 if unknown.
+	Total int64
+
+	// Rate is the average number of bytes transferred per second since the transfer started.
+	Rate float64
+}
+
+// ProgressOptions configures the progress reported for a request.
+type ProgressOptions struct {
+	// Upload, if set, is called as the request body is sent. Every attempt of the
+	// request, like a redirect or a hedged request, sends the body again.
+	Upload func(Progress)
+
+	// Download, if set, is called as the response body is read.
+	Download func(Progress)
+
+	// Interval is the minimum time between two calls. The transfer ending is always
+	// reported. Default is 100 milliseconds.
+	Interval time.Duration
+}
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx reporting the progress of the request body
+// upload and response body download of requests sent with it.
+func WithProgress(ctx context.Context, options ProgressOptions) context.Context {
+	if options.Interval <= 0 {
+		options.Interval = defaultProgressInterval
+	}
+	return context.WithValue(ctx, progressKey{}, options)
+}
+
+func progressFromContext(ctx context.Context) (ProgressOptions, bool) {
+	options, ok := ctx.Value(progressKey{}).(ProgressOptions)
+	return options, ok
+}
+
+// trackUpload wraps the body of request to report its upload progress, if set in its context.
+func trackUpload(request *http.Request) {
+	options, ok := progressFromContext(request.Context())
+	if !ok || options.Upload == nil || request.Body == nil || request.Body == http.NoBody {
+		return
+	}
+
+	total := request.ContentLength
+	if total == 0 {
+		// No body to report
+		return
+	}
+	request.Body = newProgressReader(request.Body, total, options.Upload, options.Interval)
+
+	if getBody := request.GetBody; getBody != nil {
+		request.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newProgressReader(body, total, options.Upload, options.Interval), nil
+		}
+	}
+}
+
+// trackDownload wraps the body of response to report its download progress, if set in ctx.
+func trackDownload(ctx context.Context, response *http.Response) {
+	options, ok := progressFromContext(ctx)
+	if !ok || options.Download == nil || response.Body == nil {
+		return
+	}
+	response.Body = newProgressReader(response.Body, response.ContentLength, options.Download, options.Interval)
+}
+
+// progressReader reports the bytes read through it, at most once per interval,
+// and always once the body is read to its end.
+type progressReader struct {
+	io.ReadCloser
+	handler  func(Progress)
+	interval time.Duration
+
+	mutex       sync.Mutex
+	start       time.Time
+	lastReport  time.Time
+	transferred int64
+	total       int64
+	done        bool
+}
+
+func newProgressReader(body io.ReadCloser, total int64, handler func(Progress), interval time.Duration) *progressReader {
+	return &progressReader{
+		ReadCloser: body,
+		handler:    handler,
+		interval:   interval,
+		start:      time.Now(),
+		total:      total,
+	}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	r.mutex.Lock()
+	r.transferred += int64(n)
+	now := time.Now()
+	// The transport may stop reading a request body once it got ContentLength bytes
+	ended := err == io.EOF || (r.total > 0 && r.transferred >= r.total)
+	report := !r.done && (ended || (n > 0 && now.Sub(r.lastReport) >= r.interval))
+	if report {
+		r.lastReport = now
+		r.done = ended
+	}
+	progress := Progress{
+		Transferred: r.transferred,
+		Total:       r.total,
+		Rate:        transferRate(r.transferred, now.Sub(r.start)),
+	}
+	r.mutex.Unlock()
+
+	if report {
+		r.handler(progress)
+	}
+	return n, err
+}
+
+// transferRate returns the bytes per second of transferred bytes in elapsed time.
+func transferRate(transferred int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(transferred) / elapsed.Seconds()
+}
@@ -0,0 +1,90 @@
+package gohttpclient
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadProgress(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	body := strings.Repeat("a", 1<<20)
+	var uploads []Progress
+	ctx := WithProgress(context.Background(), ProgressOptions{
+		Upload: func(p Progress) {
+			uploads = append(uploads, p)
+		},
+	})
+
+	// Execution
+	response, err := NewBuilder().Build().Do(ctx, http.MethodPost, server.URL, nil, body)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	response.Body.Close()
+	if len(uploads) == 0 {
+		t.Fatal("Upload progress should be reported")
+	}
+	// The JSON string is quoted
+	total := int64(len(body) + 2)
+	if last := uploads[len(uploads)-1]; last.Transferred != total || last.Total != total || last.Rate <= 0 {
+		t.Errorf("Invalid last upload progress %+v", last)
+	}
+}
+
+func TestDownloadProgressThrottled(t *testing.T) {
+
+	// Initialization
+	chunk := strings.Repeat("b", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(10*len(chunk)))
+		for i := 0; i < 10; i++ {
+			io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	var downloads []Progress
+	ctx := WithProgress(context.Background(), ProgressOptions{
+		Download: func(p Progress) {
+			downloads = append(downloads, p)
+		},
+		Interval: time.Hour,
+	})
+
+	// Execution
+	response, err := NewBuilder().Build().Do(ctx, http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+
+	// Validation
+	if err != nil || len(data) != 10*len(chunk) {
+		t.Fatalf("Invalid body of %d bytes, error %v", len(data), err)
+	}
+	// The first read, and the end
+	if len(downloads) != 2 {
+		t.Fatalf("Progress should be throttled, got %+v", downloads)
+	}
+	if last := downloads[1]; last.Transferred != int64(len(data)) || last.Total != int64(len(data)) {
+		t.Errorf("Invalid last download progress %+v", last)
+	}
+}
@@ -0,0 +1,144 @@
+package gohttpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RedactedValue replaces every redacted value.
+const RedactedValue = "[REDACTED]"
+
+// RedactionPolicy lists the secrets to hide before requests and responses are written
+// anywhere, like logs.
+type RedactionPolicy struct {
+	// Headers lists header names whose values are redacted, case insensitive.
+	Headers []string
+
+	// QueryParams lists query parameter names whose values are redacted, case insensitive.
+	QueryParams []string
+
+	// JSONFields lists dot separated paths, from the document root, of JSON body fields
+	// whose values are redacted. Array elements are selected by index, and a "*" segment
+	// matches any object key or array element, so "items.*.token" redacts the token
+	// of every element of the items array.
+	JSONFields []string
+}
+
+// DefaultRedactionPolicy redacts the Authorization, Proxy-Authorization, Cookie
+// and Set-Cookie headers.
+func DefaultRedactionPolicy() RedactionPolicy {
+	return RedactionPolicy{
+		Headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+	}
+}
+
+// RedactHeaders returns a copy of the headers with the policy headers redacted.
+func (p RedactionPolicy) RedactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	if redacted == nil {
+		return nil
+	}
+	for headerKey, headerValues := range redacted {
+		if !containsFold(p.Headers, headerKey) {
+			continue
+		}
+		values := make([]string, len(headerValues))
+		for i := range values {
+			values[i] = RedactedValue
+		}
+		redacted[headerKey] = values
+	}
+	return redacted
+}
+
+// RedactURL returns the URL as a string with the policy query parameters redacted.
+// Any password in the URL user info is redacted too.
+func (p RedactionPolicy) RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redacted := *u
+
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			redacted.User = url.UserPassword(u.User.Username(), RedactedValue)
+		}
+	}
+
+	if len(p.QueryParams) > 0 && u.RawQuery != "" {
+		pairs := strings.Split(u.RawQuery, "&")
+		for i, pair := range pairs {
+			key := pair
+			if eq := strings.IndexByte(pair, '='); eq >= 0 {
+				key = pair[:eq]
+			}
+			if unescapedKey, err := url.QueryUnescape(key); err == nil && containsFold(p.QueryParams, unescapedKey) {
+				pairs[i] = key + "=" + url.QueryEscape(RedactedValue)
+			}
+		}
+		redacted.RawQuery = strings.Join(pairs, "&")
+	}
+	return redacted.String()
+}
+
+// RedactJSON returns the body with the policy JSON fields redacted.
+// Bodies that aren't valid JSON, or when the policy has no JSON fields,
+// are returned unchanged.
+func (p RedactionPolicy) RedactJSON(body []byte) []byte {
+	if len(p.JSONFields) == 0 || len(bytes.TrimSpace(body)) == 0 {
+		return body
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return body
+	}
+
+	for _, field := range p.JSONFields {
+		document = redactJSONPath(document, strings.Split(field, "."))
+	}
+
+	redacted, err := json.Marshal(document)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+func redactJSONPath(node interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return RedactedValue
+	}
+	segment, rest := path[0], path[1:]
+
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		for key, value := range typedNode {
+			if segment == "*" || key == segment {
+				typedNode[key] = redactJSONPath(value, rest)
+			}
+		}
+	case []interface{}:
+		for i, value := range typedNode {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				typedNode[i] = redactJSONPath(value, rest)
+			}
+		}
+	}
+	return node
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
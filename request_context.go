@@ -0,0 +1,60 @@
+package gohttpclient
+
+import "context"
+
+type routeTemplateKey struct{}
+
+// WithRouteTemplate returns a copy of ctx carrying the unexpanded route of the request,
+// like "/users/{id}". It's used instead of the actual path to label metrics
+// and logs, keeping their cardinality low.
+func WithRouteTemplate(ctx context.Context, template string) context.Context {
+	return context.WithValue(ctx, routeTemplateKey{}, template)
+}
+
+func routeTemplateFromContext(ctx context.Context) string {
+	template, _ := ctx.Value(routeTemplateKey{}).(string)
+	return template
+}
+
+type streamingKey struct{}
+
+// WithStreaming returns a copy of ctx making requests sent with it not limited by the
+// client total timeout, so long lived or huge bodies, like event streams or bulk
+// exports, can be read. ctx, and the connection and response header timeouts,
+// still apply. Streamed requests are neither hedged, cached nor shared with coalesced
+// requests.
+func WithStreaming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingKey{}, true)
+}
+
+func isStreaming(ctx context.Context) bool {
+	streaming, _ := ctx.Value(streamingKey{}).(bool)
+	return streaming
+}
+
+type attemptKey struct{}
+
+// withAttempt returns a copy of ctx for the given attempt of a request, starting at 1.
+// Resends of the same request get greater attempts.
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+func attemptFromContext(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// sharedContext returns a context for work shared by several requests, like a coalesced
+// round-trip. It outlives the request that started it, and only keeps the values
+// describing the request, not the ones of its caller, like its curl handler,
+// progress callback, bandwidth limit or span.
+func sharedContext(ctx context.Context) context.Context {
+	shared := context.Background()
+	if template := routeTemplateFromContext(ctx); template != "" {
+		shared = WithRouteTemplate(shared, template)
+	}
+	return shared
+}
@@ -0,0 +1,267 @@
+package gohttpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Fallback delay of the dialer when not set, like net.Dialer
+	defaultFallbackDelay time.Duration = 300 * time.Millisecond
+
+	// Minimum time to dial each address, when the dial timeout allows it
+	minDialTimeout time.Duration = 2 * time.Second
+)
+
+// hostResolver resolves the hosts the client connects to, using the static overrides
+// first and the DNS cache, if enabled, before asking the resolver.
+type hostResolver struct {
+	overrides map[string]string
+	resolver  *net.Resolver
+
+	cacheTTL         time.Duration
+	negativeCacheTTL time.Duration
+	now              func() time.Time
+
+	mutex sync.Mutex
+	cache map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+func newHostResolver(overrides map[string]string, resolver *net.Resolver, cacheTTL time.Duration, negativeCacheTTL time.Duration) *hostResolver {
+	normalized := make(map[string]string, len(overrides))
+	for host, address := range overrides {
+		normalized[strings.ToLower(host)] = address
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &hostResolver{
+		overrides:        normalized,
+		resolver:         resolver,
+		cacheTTL:         cacheTTL,
+		negativeCacheTTL: negativeCacheTTL,
+		now:              time.Now,
+		cache:            make(map[string]dnsCacheEntry),
+	}
+}
+
+// dialContext returns a DialContext function connecting to the addresses the hosts
+// resolve to, the way net.Dialer does: the dialer Timeout caps the whole attempt,
+// shared between the addresses, and the ones of the other IP family are raced after
+// the dialer FallbackDelay (RFC 6555).
+func (r *hostResolver) dialContext(dialer *net.Dialer) func(ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if override, ok := r.overrides[strings.ToLower(host)]; ok {
+			return dialer.DialContext(ctx, network, net.JoinHostPort(override, port))
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+
+		ips, err := r.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var matching []net.IP
+		for _, ip := range ips {
+			if matchesNetwork(network, ip) {
+				matching = append(matching, ip)
+			}
+		}
+		if len(matching) == 0 {
+			return nil, &net.DNSError{Err: "no suitable address found", Name: host}
+		}
+
+		if dialer.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+			defer cancel()
+		}
+		// The deadline of every address is set on its context
+		addressDialer := *dialer
+		addressDialer.Timeout = 0
+
+		primaries, fallbacks := matching, []net.IP(nil)
+		if dialer.FallbackDelay >= 0 && (network == "tcp" || network == "udp") {
+			primaries, fallbacks = splitByFamily(matching)
+		}
+		fallbackDelay := dialer.FallbackDelay
+		if fallbackDelay <= 0 {
+			fallbackDelay = defaultFallbackDelay
+		}
+		return dialParallel(ctx, addressDialer.DialContext, network, port, primaries, fallbacks, fallbackDelay)
+	}
+}
+
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+// dialParallel dials the primaries, and the fallbacks once the primaries failed or
+// after the fallback delay, returning the first connection established.
+func dialParallel(ctx context.Context, dial func(ctx context.Context, network string, address string) (net.Conn, error), network string, port string, primaries []net.IP, fallbacks []net.IP, fallbackDelay time.Duration) (net.Conn, error) {
+	if len(fallbacks) == 0 {
+		return dialSerial(ctx, dial, network, port, primaries)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, 2)
+	start := func(ips []net.IP, primary bool) {
+		go func() {
+			conn, err := dialSerial(ctx, dial, network, port, ips)
+			results <- dialResult{conn: conn, err: err, primary: primary}
+		}()
+	}
+	start(primaries, true)
+	pending := 1
+	fallbackStarted := false
+	startFallback := func() {
+		if !fallbackStarted {
+			fallbackStarted = true
+			pending++
+			start(fallbacks, false)
+		}
+	}
+
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+
+	var primaryErr error
+	for {
+		select {
+		case <-timer.C:
+			startFallback()
+
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if pending > 0 {
+					// The other racer is cancelled, and closed if it connected anyway
+					go func() {
+						if other := <-results; other.conn != nil {
+							other.conn.Close()
+						}
+					}()
+				}
+				return result.conn, nil
+			}
+			if result.primary {
+				primaryErr = result.err
+			} else if primaryErr == nil {
+				primaryErr = result.err
+			}
+			startFallback()
+			if pending == 0 {
+				return nil, primaryErr
+			}
+		}
+	}
+}
+
+// dialSerial dials the IPs in turn until a connection is established, giving each one
+// a share of the time left before the ctx deadline.
+func dialSerial(ctx context.Context, dial func(ctx context.Context, network string, address string) (net.Conn, error), network string, port string, ips []net.IP) (net.Conn, error) {
+	var dialErr error
+	for i, ip := range ips {
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			dialCtx, cancel = context.WithDeadline(ctx, partialDeadline(time.Now(), deadline, len(ips)-i))
+		}
+		conn, err := dial(dialCtx, network, net.JoinHostPort(ip.String(), port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, dialErr
+}
+
+// partialDeadline returns the deadline of dialing one of the addresses left, sharing
+// the time left between them, but giving each one at least minDialTimeout if possible.
+func partialDeadline(now time.Time, deadline time.Time, addressesLeft int) time.Time {
+	left := deadline.Sub(now)
+	timeout := left / time.Duration(addressesLeft)
+	if timeout < minDialTimeout {
+		timeout = minDialTimeout
+		if left < minDialTimeout {
+			timeout = left
+		}
+	}
+	return now.Add(timeout)
+}
+
+// splitByFamily splits the IPs between the ones of the family of the first IP and the others.
+func splitByFamily(ips []net.IP) ([]net.IP, []net.IP) {
+	var primaries, fallbacks []net.IP
+	firstIsV4 := ips[0].To4() != nil
+	for _, ip := range ips {
+		if (ip.To4() != nil) == firstIsV4 {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// lookup returns the IPs of the host from the cache, or from the resolver when
+// they're not cached or expired. Failed lookups are cached for the negative TTL.
+func (r *hostResolver) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	key := strings.ToLower(host)
+
+	r.mutex.Lock()
+	entry, ok := r.cache[key]
+	r.mutex.Unlock()
+	if ok && r.now().Before(entry.expires) {
+		return entry.ips, entry.err
+	}
+
+	ips, err := r.resolver.LookupIP(ctx, "ip", host)
+
+	ttl := r.cacheTTL
+	if err != nil {
+		ttl = 0
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			ttl = r.negativeCacheTTL
+		}
+	}
+	if ttl > 0 {
+		r.mutex.Lock()
+		r.cache[key] = dnsCacheEntry{ips: ips, err: err, expires: r.now().Add(ttl)}
+		r.mutex.Unlock()
+	}
+	return ips, err
+}
+
+func matchesNetwork(network string, ip net.IP) bool {
+	switch network {
+	case "tcp4", "udp4":
+		return ip.To4() != nil
+	case "tcp6", "udp6":
+		return ip.To4() == nil
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package gohttpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFakeResolver(dns *fakeDNSServer) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "udp", dns.Addr())
+		},
+	}
+}
+
+func TestHostOverrides(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	c := NewBuilder().SetHostOverrides(map[string]string{"API.internal.test": "127.0.0.1"}).Build()
+
+	// Execution
+	resp, err := c.GET("http://api.internal.test:"+port+"/", nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Invalid status %d", resp.StatusCode)
+	}
+}
+
+func TestDNSCache(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	dns := newFakeDNSServer(t)
+	defer dns.Close()
+	dns.SetIP("cached.test", net.ParseIP("127.0.0.1"))
+
+	c := NewBuilder().SetResolver(newFakeResolver(dns)).SetDNSCache(time.Minute, time.Minute).Build().(*client)
+	now := time.Now()
+	c.hostResolver.now = func() time.Time { return now }
+
+	lookup := func(host string) error {
+		_, err := c.hostResolver.lookup(context.Background(), host)
+		return err
+	}
+
+	// Execution
+	resp, err := c.GET("http://cached.test:"+port+"/", nil)
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	queriesAfterFirst := dns.Queries()
+	lookup("cached.test")
+	queriesAfterCached := dns.Queries()
+
+	missingErr := lookup("missing.test")
+	queriesAfterMissing := dns.Queries()
+	cachedMissingErr := lookup("missing.test")
+	queriesAfterCachedMissing := dns.Queries()
+
+	now = now.Add(2 * time.Minute)
+	lookup("cached.test")
+	queriesAfterExpiry := dns.Queries()
+
+	// Validation
+	if queriesAfterFirst == 0 {
+		t.Error("Custom resolver was not used")
+	}
+	if queriesAfterCached != queriesAfterFirst {
+		t.Error("Cached address was looked up again")
+	}
+	if missingErr == nil || cachedMissingErr == nil || queriesAfterCachedMissing != queriesAfterMissing {
+		t.Errorf("Host not found should be cached: %v, %v", missingErr, cachedMissingErr)
+	}
+	if queriesAfterExpiry == queriesAfterCachedMissing {
+		t.Error("Expired address was not looked up again")
+	}
+}
+
+// blackHoleDial dials conn for the addresses in reachable, and waits for ctx to be done
+// for the other ones.
+func blackHoleDial(reachable string, conn net.Conn) func(ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if address == reachable {
+			return conn, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+}
+
+func TestDialFallback(t *testing.T) {
+
+	// Initialization
+	conn, other := net.Pipe()
+	defer conn.Close()
+	defer other.Close()
+	dial := blackHoleDial("127.0.0.1:80", conn)
+
+	// Execution
+	start := time.Now()
+	got, err := dialParallel(context.Background(), dial, "tcp", "80",
+		[]net.IP{net.ParseIP("2001:db8::1")}, []net.IP{net.ParseIP("127.0.0.1")}, 20*time.Millisecond)
+
+	// Validation
+	if err != nil || got != conn {
+		t.Fatalf("Fallback address should be connected, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fallback address should be dialed after the fallback delay, took %v", elapsed)
+	}
+}
+
+func TestDialTimeoutIsShared(t *testing.T) {
+
+	// Initialization
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	ips := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3")}
+
+	// Execution
+	start := time.Now()
+	_, err := dialSerial(ctx, blackHoleDial("", nil), "tcp", "80", ips)
+
+	// Validation
+	if err == nil {
+		t.Fatal("Unreachable addresses should not be connected")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Dialing every address should take the dial timeout, took %v", elapsed)
+	}
+}
+
+func TestPartialDeadline(t *testing.T) {
+
+	// Initialization
+	now := time.Now()
+
+	// Execution
+	shared := partialDeadline(now, now.Add(30*time.Second), 3)
+	minimum := partialDeadline(now, now.Add(3*time.Second), 3)
+	left := partialDeadline(now, now.Add(time.Second), 3)
+
+	// Validation
+	if shared.Sub(now) != 10*time.Second || minimum.Sub(now) != 2*time.Second || left.Sub(now) != time.Second {
+		t.Errorf("Invalid partial deadlines %v, %v and %v", shared.Sub(now), minimum.Sub(now), left.Sub(now))
+	}
+}
@@ -0,0 +1,109 @@
+package gohttpclient
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    time.Duration = 100 * time.Millisecond
+	defaultRetryMaxBackoff time.Duration = 10 * time.Second
+)
+
+// RetryPolicy configures how requests failing with a retryable error are sent again.
+// Idempotent requests (GET, HEAD, OPTIONS, TRACE, PUT and DELETE) are retried on
+// connection errors and 429, 502, 503 and 504 responses; other requests only when
+// the connection couldn't be established, since the server never got them.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a request is sent again. Zero disables retries.
+	MaxRetries int
+
+	// Backoff is the delay before the first retry, doubled on every following one,
+	// each delay being randomized between half and all of it. Default is 100 milliseconds.
+	Backoff time.Duration
+
+	// MaxBackoff is the maximum delay between retries, also limiting the one asked
+	// by the Retry-After header of a response. Default is 10 seconds.
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	delay := backoff
+	for i := 1; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (p RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return defaultRetryMaxBackoff
+	}
+	return p.MaxBackoff
+}
+
+// sendWithRetries sends a request, and sends it again while it fails with a retryable
+// error and the policy allows it. The response of the last attempt is returned.
+func (c *client) sendWithRetries(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+	policy := c.builder.retryPolicy
+	attempt := attemptFromContext(ctx)
+
+	for retry := 1; ; retry++ {
+		response, err := c.dispatch(withAttempt(ctx, attempt), method, url, headers, body)
+		if retry > policy.MaxRetries || ctx.Err() != nil || !isRetryable(method, response, err) {
+			return response, err
+		}
+
+		delay := policy.backoff(retry)
+		if err == nil {
+			if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok && retryAfter > delay {
+				delay = retryAfter
+				if delay > policy.maxBackoff() {
+					delay = policy.maxBackoff()
+				}
+			}
+			discardBody(response)
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		attempt++
+	}
+}
+
+// isRetryable tells whether a failed request can be sent again.
+func isRetryable(method string, response *http.Response, err error) bool {
+	if err == nil && response.StatusCode == http.StatusTooManyRequests {
+		return isHedgeable(method)
+	}
+	return isFailoverable(method, response, err)
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+package gohttpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("recovered"))
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetRetryPolicy(RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond}).Build()
+
+	// Execution
+	resp, err := c.GET(server.URL, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "recovered" {
+		t.Errorf("Invalid response %d %q", resp.StatusCode, body)
+	}
+	if hits != 3 {
+		t.Errorf("Request should be sent 3 times, got %d", hits)
+	}
+}
+
+func TestRetryPolicyNonIdempotent(t *testing.T) {
+
+	// Initialization
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewBuilder().SetRetryPolicy(RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond}).Build()
+
+	// Execution
+	resp, err := c.POST(server.URL, nil, map[string]string{"name": "item"})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Invalid status %d", resp.StatusCode)
+	}
+	if hits != 1 {
+		t.Errorf("POST answered by the server should not be retried, sent %d times", hits)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+
+	// Execution
+	seconds, secondsOk := parseRetryAfter("2")
+	date, dateOk := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	_, invalidOk := parseRetryAfter("soon")
+
+	// Validation
+	if !secondsOk || seconds != 2*time.Second {
+		t.Errorf("Invalid delay %v", seconds)
+	}
+	if !dateOk || date < 58*time.Second || date > time.Minute {
+		t.Errorf("Invalid delay %v", date)
+	}
+	if invalidOk {
+		t.Error("Invalid Retry-After should be ignored")
+	}
+}
@@ -0,0 +1,301 @@
+package gohttpclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	iso8601BasicFormat      = "20060102T150405Z"
+	iso8601BasicFormatShort = "20060102"
+
+	// UnsignedPayload is used instead of the body hash when the payload is not signed.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// RequestSigner signs a request once its headers and body are final.
+// The body is the exact payload being sent, as marshaled by the client,
+// and is nil when the request doesn't carry one.
+// Implementations usually add an Authorization header and must be safe for
+// concurrent use by multiple goroutines.
+type RequestSigner interface {
+	Sign(request *http.Request, body []byte) error
+}
+
+// HMACSigner is a generic HMAC-SHA256 request signer.
+// It signs a canonical request made of the method, the path, the sorted query,
+// the selected headers and the payload hash, and sets the resulting
+// Authorization header in the form:
+//
+//	HMAC-SHA256 Credential=<KeyID>, SignedHeaders=<h1;h2>, Signature=<hex>
+//
+// X-Date and X-Content-Sha256 headers are added to the request and always signed,
+// as well as the Host header.
+type HMACSigner struct {
+	KeyID  string
+	Secret []byte
+
+	// SignedHeaders lists additional header names to include in the signature.
+	// Headers missing on the request are signed with an empty value.
+	SignedHeaders []string
+
+	// UnsignedPayload replaces the body hash with UNSIGNED-PAYLOAD,
+	// so the body can be streamed without hashing it first.
+	UnsignedPayload bool
+
+	// Now returns the signing time. Default is time.Now.
+	Now func() time.Time
+}
+
+func (s *HMACSigner) Sign(request *http.Request, body []byte) error {
+	if s.KeyID == "" || len(s.Secret) == 0 {
+		return fmt.Errorf("hmac signer requires a key id and a secret")
+	}
+
+	timestamp := signingTime(s.Now).Format(iso8601BasicFormat)
+	payloadHash := UnsignedPayload
+	if !s.UnsignedPayload {
+		payloadHash = hashSHA256Hex(body)
+	}
+	request.Header.Set("X-Date", timestamp)
+	request.Header.Set("X-Content-Sha256", payloadHash)
+
+	signedHeaders := normalizeSignedHeaders(append([]string{"host", "x-date", "x-content-sha256"}, s.SignedHeaders...))
+	canonical := canonicalRequest(request, signedHeaders, payloadHash, false)
+
+	stringToSign := strings.Join([]string{
+		"HMAC-SHA256",
+		timestamp,
+		hashSHA256Hex([]byte(canonical)),
+	}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(s.Secret, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("HMAC-SHA256 Credential=%s, SignedHeaders=%s, Signature=%s",
+		s.KeyID, strings.Join(signedHeaders, ";"), signature))
+	return nil
+}
+
+// AWSV4Signer signs requests with AWS Signature Version 4.
+// Host, Content-Type, Content-MD5 and every X-Amz-* header present
+// on the request are signed.
+type AWSV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent as X-Amz-Security-Token when using temporary credentials.
+	SessionToken string
+
+	Region  string
+	Service string
+
+	// SignedHeaders lists additional header names to include in the signature.
+	SignedHeaders []string
+
+	// UnsignedPayload sends X-Amz-Content-Sha256: UNSIGNED-PAYLOAD instead of
+	// hashing the body. Used by services like S3 to stream large payloads.
+	UnsignedPayload bool
+
+	// Now returns the signing time. Default is time.Now.
+	Now func() time.Time
+}
+
+func (s *AWSV4Signer) Sign(request *http.Request, body []byte) error {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return fmt.Errorf("aws v4 signer requires an access key id and a secret access key")
+	}
+	if s.Region == "" || s.Service == "" {
+		return fmt.Errorf("aws v4 signer requires a region and a service")
+	}
+
+	t := signingTime(s.Now)
+	amzDate := t.Format(iso8601BasicFormat)
+	date := t.Format(iso8601BasicFormatShort)
+
+	isS3 := s.Service == "s3"
+
+	payloadHash := UnsignedPayload
+	if !s.UnsignedPayload {
+		payloadHash = hashSHA256Hex(body)
+	}
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	if isS3 || s.UnsignedPayload {
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if s.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	names := append([]string{"host"}, s.SignedHeaders...)
+	for headerKey := range request.Header {
+		lowerKey := strings.ToLower(headerKey)
+		if strings.HasPrefix(lowerKey, "x-amz-") || lowerKey == "content-type" || lowerKey == "content-md5" {
+			names = append(names, lowerKey)
+		}
+	}
+	signedHeaders := normalizeSignedHeaders(names)
+
+	// Every service but S3 expects the path to be encoded twice.
+	canonical := canonicalRequest(request, signedHeaders, payloadHash, !isS3)
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashSHA256Hex([]byte(canonical)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.Region)
+	signingKey = hmacSHA256(signingKey, s.Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, strings.Join(signedHeaders, ";"), signature))
+	return nil
+}
+
+// canonicalRequest builds the canonical form of the request shared by the signers:
+// method, URI, query, headers, signed header names and payload hash, one per line.
+func canonicalRequest(request *http.Request, signedHeaders []string, payloadHash string, doubleEscapePath bool) string {
+	return strings.Join([]string{
+		request.Method,
+		canonicalURI(request.URL, doubleEscapePath),
+		canonicalQuery(request.URL),
+		canonicalHeaders(request, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func canonicalURI(u *url.URL, doubleEscape bool) string {
+	uriPath := u.Path
+	if uriPath == "" {
+		return "/"
+	}
+	if doubleEscape {
+		cleaned := path.Clean(uriPath)
+		if strings.HasSuffix(uriPath, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		uriPath = cleaned
+	}
+
+	escaped := uriEncode(uriPath, false)
+	if doubleEscape {
+		escaped = uriEncode(escaped, false)
+	}
+	return escaped
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(query))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders returns one "name:value\n" line per signed header.
+func canonicalHeaders(request *http.Request, signedHeaders []string) string {
+	var builder strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		if name == "host" {
+			value = request.Host
+			if value == "" {
+				value = request.URL.Host
+			}
+		} else {
+			values := request.Header.Values(name)
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(trimmed, ",")
+		}
+		builder.WriteString(name)
+		builder.WriteString(":")
+		builder.WriteString(value)
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// normalizeSignedHeaders lowercases, sorts and removes duplicates from header names.
+func normalizeSignedHeaders(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// uriEncode percent-encodes every byte but the RFC 3986 unreserved characters.
+// Slashes are kept as they are unless encodeSlash is true.
+func uriEncode(value string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9',
+			char == '-', char == '_', char == '.', char == '~':
+			builder.WriteByte(char)
+		case char == '/' && !encodeSlash:
+			builder.WriteByte(char)
+		default:
+			builder.WriteByte('%')
+			builder.WriteByte(hexDigits[char>>4])
+			builder.WriteByte(hexDigits[char&0x0F])
+		}
+	}
+	return builder.String()
+}
+
+func signingTime(now func() time.Time) time.Time {
+	if now == nil {
+		return time.Now().UTC()
+	}
+	return now().UTC()
+}
+
+func hashSHA256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,152 @@
+package gohttpclient
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAWSV4SignerGetVanilla(t *testing.T) {
+
+	// Initialization
+	// get-vanilla case from the AWS Signature Version 4 test suite
+	signer := &AWSV4Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+		Now: func() time.Time {
+			return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+		},
+	}
+	request, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+
+	// Execution
+	err := signer.Sign(request, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Cannot sign request. %v", err)
+	}
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if request.Header.Get("Authorization") != expected {
+		t.Errorf("Invalid Authorization header: %s", request.Header.Get("Authorization"))
+	}
+	if request.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+		t.Errorf("Invalid X-Amz-Date header: %s", request.Header.Get("X-Amz-Date"))
+	}
+}
+
+func TestAWSV4SignerUnsignedPayload(t *testing.T) {
+
+	// Initialization
+	signer := &AWSV4Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		Region:          "us-east-1",
+		Service:         "s3",
+		UnsignedPayload: true,
+	}
+	request, _ := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/key", strings.NewReader("payload"))
+
+	// Execution
+	err := signer.Sign(request, []byte("payload"))
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Cannot sign request. %v", err)
+	}
+	if request.Header.Get("X-Amz-Content-Sha256") != UnsignedPayload {
+		t.Errorf("Invalid X-Amz-Content-Sha256 header: %s", request.Header.Get("X-Amz-Content-Sha256"))
+	}
+	if !strings.Contains(request.Header.Get("Authorization"), "x-amz-content-sha256") {
+		t.Error("X-Amz-Content-Sha256 header is not signed")
+	}
+}
+
+func TestCanonicalRequest(t *testing.T) {
+
+	// Initialization
+	request, _ := http.NewRequest(http.MethodGet, "https://example.com/a b/c?z=1&a=2&a=1&m=x+y", nil)
+	request.Header.Set("X-Custom", "  some   value ")
+
+	// Execution
+	canonical := canonicalRequest(request, []string{"host", "x-custom"}, "hash", false)
+
+	// Validation
+	expected := "GET\n" +
+		"/a%20b/c\n" +
+		"a=1&a=2&m=x%20y&z=1\n" +
+		"host:example.com\n" +
+		"x-custom:some value\n" +
+		"\n" +
+		"host;x-custom\n" +
+		"hash"
+	if canonical != expected {
+		t.Errorf("Invalid canonical request:\n%s", canonical)
+	}
+}
+
+func TestHMACSignerSignsMarshaledBody(t *testing.T) {
+
+	// Initialization
+	secret := []byte("secret")
+	signingTime := time.Date(2021, 5, 10, 8, 0, 0, 0, time.UTC)
+
+	var receivedAuthorization, expectedAuthorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		receivedAuthorization = r.Header.Get("Authorization")
+
+		// Server side verification recomputes the signature over the received request
+		verifier := &HMACSigner{KeyID: "key-1", Secret: secret, SignedHeaders: []string{"Content-Type"},
+			Now: func() time.Time { return signingTime }}
+		r.URL.Host = r.Host
+		verifier.Sign(r, body)
+		expectedAuthorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	c := NewBuilder().
+		SetRequestSigner(&HMACSigner{KeyID: "key-1", Secret: secret, SignedHeaders: []string{"Content-Type"},
+			Now: func() time.Time { return signingTime }}).
+		Build()
+
+	// Execution
+	resp, err := c.POST(server.URL+"/items?b=2&a=1", nil, map[string]string{"name": "item"})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing POST: %v", err)
+	}
+	resp.Body.Close()
+
+	if receivedAuthorization == "" {
+		t.Fatal("Authorization header was not sent")
+	}
+	if receivedAuthorization != expectedAuthorization {
+		t.Errorf("Signature mismatch. Sent %s, expected %s", receivedAuthorization, expectedAuthorization)
+	}
+	if !strings.Contains(receivedAuthorization, "SignedHeaders=content-type;host;x-content-sha256;x-date") {
+		t.Errorf("Invalid signed headers: %s", receivedAuthorization)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	if uriEncode("a/b c~", false) != "a/b%20c~" {
+		t.Error("Invalid encoding keeping slashes")
+	}
+	if uriEncode("a/b", true) != "a%2Fb" {
+		t.Error("Invalid encoding of slashes")
+	}
+	if uriEncode(string([]byte{0xff}), true) != "%"+strings.ToUpper(hex.EncodeToString([]byte{0xff})) {
+		t.Error("Invalid encoding of non ASCII bytes")
+	}
+}
@@ -0,0 +1,266 @@
+package gohttpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReconnectDelay time.Duration = 3 * time.Second
+
+	// Longest line of an event stream
+	maxEventLineSize = 10 << 20
+)
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID is the last event ID set by the stream, sent back when reconnecting.
+	ID string
+
+	// Type of the event. Default is "message".
+	Type string
+
+	Data string
+
+	// Retry is the reconnection delay set by the event, zero if none.
+	Retry time.Duration
+}
+
+// EventSource consumes a text/event-stream, as described in the HTML Living Standard.
+// It reconnects when the stream ends or the connection fails, sending the last event ID
+// received in the Last-Event-ID header, and waiting the delay set by the server.
+// Requests are sent with WithStreaming so the client total timeout doesn't end them.
+type EventSource struct {
+	// ReconnectDelay is the delay before reconnecting, until the server sets one.
+	// Default is 3 seconds.
+	ReconnectDelay time.Duration
+
+	// MaxReconnects is the maximum number of reconnections in a row without receiving
+	// any event. Zero means no limit.
+	MaxReconnects int
+
+	client  Doer
+	url     string
+	headers http.Header
+
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewEventSource returns an EventSource reading the stream at url.
+func NewEventSource(client Doer, url string, headers http.Header) *EventSource {
+	return &EventSource{
+		client:  client,
+		url:     url,
+		headers: headers,
+	}
+}
+
+// LastEventID returns the ID of the last event received.
+func (s *EventSource) LastEventID() string {
+	return s.lastEventID
+}
+
+// Listen calls handler with every event received, until ctx is cancelled or the stream
+// fails in a way reconnecting doesn't fix, like a 4xx response, or a 204 response the
+// server sends to stop clients. It returns nil only in this last case.
+func (s *EventSource) Listen(ctx context.Context, handler func(Event)) error {
+	reconnects := 0
+	for {
+		received, err := s.stream(ctx, handler)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == errStreamClosed {
+			return nil
+		}
+		if _, ok := err.(*streamError); ok {
+			return err
+		}
+
+		if received {
+			reconnects = 0
+		}
+		reconnects++
+		if s.MaxReconnects > 0 && reconnects > s.MaxReconnects {
+			if err == nil {
+				err = io.EOF
+			}
+			return fmt.Errorf("unable to reconnect to event stream. %v", err)
+		}
+
+		select {
+		case <-time.After(s.reconnectDelay()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Events returns a channel with the events received, closed once Listen would return.
+// The error it would return is sent to errs, if not nil.
+func (s *EventSource) Events(ctx context.Context) (events <-chan Event, errs <-chan error) {
+	eventsChan := make(chan Event)
+	errsChan := make(chan error, 1)
+	go func() {
+		defer close(eventsChan)
+		defer close(errsChan)
+
+		err := s.Listen(ctx, func(event Event) {
+			select {
+			case eventsChan <- event:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil {
+			errsChan <- err
+		}
+	}()
+	return eventsChan, errsChan
+}
+
+func (s *EventSource) reconnectDelay() time.Duration {
+	if s.retry > 0 {
+		return s.retry
+	}
+	if s.ReconnectDelay > 0 {
+		return s.ReconnectDelay
+	}
+	return defaultReconnectDelay
+}
+
+// streamError is a failure reconnecting doesn't fix.
+type streamError struct {
+	message string
+}
+
+func (e *streamError) Error() string {
+	return e.message
+}
+
+var errStreamClosed = &streamError{message: "event stream closed by the server"}
+
+// stream reads events until the stream ends, telling whether any was received.
+func (s *EventSource) stream(ctx context.Context, handler func(Event)) (bool, error) {
+	headers := s.headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set("Accept", "text/event-stream")
+	headers.Set("Cache-Control", "no-store")
+	if s.lastEventID != "" {
+		headers.Set("Last-Event-ID", s.lastEventID)
+	}
+
+	response, err := s.client.Do(WithStreaming(ctx), http.MethodGet, s.url, headers, nil)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNoContent:
+		return false, errStreamClosed
+	case response.StatusCode >= http.StatusInternalServerError:
+		return false, fmt.Errorf("event stream failed with status %d", response.StatusCode)
+	case response.StatusCode != http.StatusOK:
+		return false, &streamError{message: fmt.Sprintf("event stream failed with status %d", response.StatusCode)}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return false, &streamError{message: fmt.Sprintf("invalid event stream content type %q", response.Header.Get("Content-Type"))}
+	}
+
+	return s.parse(response.Body, handler)
+}
+
+// parse dispatches the events in the stream, telling whether any was dispatched.
+// An event not ended by a blank line when the stream ends is discarded.
+func (s *EventSource) parse(body io.Reader, handler func(Event)) (bool, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, maxEventLineSize)
+	scanner.Split(scanEventLines)
+
+	received := false
+	var eventType string
+	var data strings.Builder
+	var retry time.Duration
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() > 0 {
+				event := Event{
+					ID:    s.lastEventID,
+					Type:  eventType,
+					Data:  strings.TrimSuffix(data.String(), "\n"),
+					Retry: retry,
+				}
+				if event.Type == "" {
+					event.Type = "message"
+				}
+				handler(event)
+				received = true
+			}
+			eventType, retry = "", 0
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if milliseconds, err := strconv.ParseUint(value, 10, 63); err == nil {
+				s.retry = time.Duration(milliseconds) * time.Millisecond
+				retry = s.retry
+			}
+		}
+	}
+	return received, scanner.Err()
+}
+
+// scanEventLines splits lines ended by CRLF, LF or CR.
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A CR at the end of the buffer may be followed by a LF not read yet
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
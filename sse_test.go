@@ -0,0 +1,129 @@
+package gohttpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventSourceReconnect(t *testing.T) {
+
+	// Initialization
+	var connections int32
+	lastEventIDs := make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEventIDs <- r.Header.Get("Last-Event-ID")
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, ": comment\r\nretry: 10\r\nid: 1\r\ndata: first\r\ndata: line\r\n\r\n")
+			fmt.Fprint(w, "event: update\nid: 2\ndata:{\"a\":1}\n\n")
+			fmt.Fprint(w, "data: incomplete")
+		case 2:
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			fmt.Fprint(w, "id: 3\rdata: third\r\r")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	source := NewEventSource(NewBuilder().Build(), server.URL, nil)
+	source.ReconnectDelay = time.Minute
+
+	// Execution
+	var events []Event
+	err := source.Listen(context.Background(), func(event Event) {
+		events = append(events, event)
+	})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Stream should end without error when the server answers 204, got %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Invalid events %+v", events)
+	}
+	if events[0] != (Event{ID: "1", Type: "message", Data: "first\nline", Retry: 10 * time.Millisecond}) {
+		t.Errorf("Invalid first event %+v", events[0])
+	}
+	if events[1] != (Event{ID: "2", Type: "update", Data: `{"a":1}`}) {
+		t.Errorf("Invalid second event %+v", events[1])
+	}
+	if events[2].ID != "3" || events[2].Data != "third" {
+		t.Errorf("Invalid third event %+v", events[2])
+	}
+	if ids := []string{<-lastEventIDs, <-lastEventIDs, <-lastEventIDs}; strings.Join(ids, ",") != ",2,3" {
+		t.Errorf("Invalid Last-Event-ID headers %q", ids)
+	}
+}
+
+func TestEventSourceChannelCancellation(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}))
+	defer server.Close()
+
+	// The stream outlives the total timeout of the client
+	c := NewBuilder().
+		SetConnectionTimeout(10 * time.Millisecond).
+		SetResponseTimeout(10 * time.Millisecond).
+		SetTLSHandshakeTimeout(10 * time.Millisecond).
+		SetExpectContinueTimeout(10 * time.Millisecond).
+		Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Execution
+	events, errs := NewEventSource(c, server.URL, nil).Events(ctx)
+	var received []string
+	for event := range events {
+		received = append(received, event.Data)
+		if len(received) == 5 {
+			cancel()
+		}
+	}
+
+	// Validation
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("Cancellation error expected, got %v", err)
+	}
+	if len(received) < 5 || received[4] != "4" {
+		t.Errorf("Invalid events %q", received)
+	}
+}
+
+func TestEventSourceFailure(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	// Execution
+	err := NewEventSource(NewBuilder().Build(), server.URL, nil).Listen(context.Background(), func(Event) {})
+
+	// Validation
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Client errors should not be retried, got %v", err)
+	}
+}
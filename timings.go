@@ -0,0 +1,153 @@
+package gohttpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTimings is the lifecycle breakdown of a request.
+// Phases that didn't happen, like DNS, connect and TLS on a reused connection,
+// are zero.
+type RequestTimings struct {
+	DNSLookup    time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+
+	// ServerProcessing goes from the request being fully written
+	// to the first response byte.
+	ServerProcessing time.Duration
+
+	// TimeToFirstByte goes from the start of the request to the first response byte.
+	TimeToFirstByte time.Duration
+
+	// Total goes from the start of the request until response headers are read.
+	// It doesn't include reading the response body.
+	Total time.Duration
+
+	ConnectionReused bool
+}
+
+// TimingsHandler is called with the timings of every request sent, after
+// response headers are read or the request fails.
+type TimingsHandler func(request *http.Request, timings RequestTimings)
+
+type requestTimerKey struct{}
+
+// requestTimer collects httptrace events. Events may come from different goroutines.
+type requestTimer struct {
+	mutex sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	end          time.Time
+	reused       bool
+}
+
+// GetRequestTimings returns the timings of the request that produced the response.
+// It returns false if the response wasn't received through a Client.
+func GetRequestTimings(response *http.Response) (RequestTimings, bool) {
+	if response == nil || response.Request == nil {
+		return RequestTimings{}, false
+	}
+	timer, ok := response.Request.Context().Value(requestTimerKey{}).(*requestTimer)
+	if !ok {
+		return RequestTimings{}, false
+	}
+	return timer.timings(), true
+}
+
+// traceRequest returns a copy of the request with a timer attached to its context.
+func traceRequest(request *http.Request) (*http.Request, *requestTimer) {
+	timer := &requestTimer{start: time.Now()}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			timer.mark(&timer.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			timer.mark(&timer.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			timer.markFirst(&timer.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			timer.mark(&timer.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			timer.mark(&timer.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timer.mark(&timer.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			timer.mutex.Lock()
+			timer.reused = info.Reused
+			timer.mutex.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			timer.mark(&timer.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			timer.markFirst(&timer.firstByte)
+		},
+	}
+
+	ctx := httptrace.WithClientTrace(request.Context(), trace)
+	ctx = context.WithValue(ctx, requestTimerKey{}, timer)
+	return request.WithContext(ctx), timer
+}
+
+func (t *requestTimer) mark(field *time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	*field = time.Now()
+}
+
+func (t *requestTimer) markFirst(field *time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+// finish marks the end of the request, once response headers are read or it failed.
+func (t *requestTimer) finish() RequestTimings {
+	t.mark(&t.end)
+	return t.timings()
+}
+
+func (t *requestTimer) timings() RequestTimings {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return RequestTimings{
+		DNSLookup:        between(t.dnsStart, t.dnsDone),
+		Connect:          between(t.connectStart, t.connectDone),
+		TLSHandshake:     between(t.tlsStart, t.tlsDone),
+		ServerProcessing: between(t.wroteRequest, t.firstByte),
+		TimeToFirstByte:  between(t.start, t.firstByte),
+		Total:            between(t.start, t.end),
+		ConnectionReused: t.reused,
+	}
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -0,0 +1,79 @@
+package gohttpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimings(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var handled []RequestTimings
+	c := NewBuilder().
+		SetTimingsHandler(func(request *http.Request, timings RequestTimings) {
+			handled = append(handled, timings)
+		}).
+		Build()
+
+	// Execution
+	var timings []RequestTimings
+	for i := 0; i < 2; i++ {
+		resp, err := c.GET(server.URL, nil)
+		if err != nil {
+			t.Fatalf("Error executing GET: %v", err)
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		requestTimings, ok := GetRequestTimings(resp)
+		if !ok {
+			t.Fatal("Timings are not available from the response")
+		}
+		timings = append(timings, requestTimings)
+	}
+
+	// Validation
+	if len(handled) != 2 {
+		t.Errorf("Timings handler was called %d times", len(handled))
+	}
+
+	first, second := timings[0], timings[1]
+	if first.ConnectionReused {
+		t.Error("First request should use a new connection")
+	}
+	if first.Connect <= 0 {
+		t.Error("Connect time was not measured")
+	}
+	if first.ServerProcessing < 10*time.Millisecond {
+		t.Errorf("Invalid server processing time %v", first.ServerProcessing)
+	}
+	if first.TimeToFirstByte < first.ServerProcessing || first.Total < first.TimeToFirstByte {
+		t.Errorf("Inconsistent timings %+v", first)
+	}
+
+	if !second.ConnectionReused {
+		t.Error("Second request should reuse the connection")
+	}
+	if second.Connect != 0 || second.DNSLookup != 0 {
+		t.Errorf("Reused connection should not have connect timings %+v", second)
+	}
+}
+
+func TestGetRequestTimingsWithoutClient(t *testing.T) {
+	if _, ok := GetRequestTimings(&http.Response{Request: httptest.NewRequest(http.MethodGet, "/", nil)}); ok {
+		t.Error("Timings should not be available")
+	}
+	if _, ok := GetRequestTimings(nil); ok {
+		t.Error("Timings should not be available")
+	}
+}
@@ -0,0 +1,192 @@
+package gohttpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Span attribute keys set by the client.
+const (
+	AttributeHTTPMethod     = "http.method"
+	AttributeHTTPURL        = "http.url"
+	AttributeHTTPStatusCode = "http.status_code"
+	AttributeHTTPAttempt    = "http.attempt"
+)
+
+// SpanContext identifies a span as defined by W3C Trace Context.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+	// TraceState is the vendor specific tracestate header value, propagated as is.
+	TraceState string
+}
+
+// IsValid reports whether both trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent returns the traceparent header value of the span.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceparent parses a traceparent header value.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent version in %q", value)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace id in traceparent %q", value)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid span id in traceparent %q", value)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, fmt.Errorf("invalid flags in traceparent %q", value)
+	}
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid all zeros id in traceparent %q", value)
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying sc as the parent span
+// of the requests made with it.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span carried by ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Span is a client span covering a single request sent by the client.
+// Resends, like the ones answering an authentication challenge, get their own span
+// with a greater AttributeHTTPAttempt.
+type Span struct {
+	Name        string
+	SpanContext SpanContext
+	// Parent is the span read from the request context. It's zero for root spans.
+	Parent SpanContext
+
+	StartTime time.Time
+	EndTime   time.Time
+
+	Attributes map[string]interface{}
+	// Err is the error returned sending the request, if any.
+	Err error
+}
+
+// Tracer receives the start and end events of every span.
+// Implementations must be safe for concurrent use by multiple goroutines
+// and must not keep a reference to the span once SpanEnded returns, copy it instead.
+type Tracer interface {
+	SpanStarted(span *Span)
+	SpanEnded(span *Span)
+}
+
+// NoopTracer discards every span. It's the default Tracer.
+type NoopTracer struct{}
+
+func (NoopTracer) SpanStarted(*Span) {}
+func (NoopTracer) SpanEnded(*Span)   {}
+
+type clientSpanKey struct{}
+
+// injectTraceContext creates the span of the request, child of the span carried by
+// its context, and sets the traceparent and tracestate headers.
+// Nothing is done when there's no parent span and no tracer is set, so requests
+// outside a trace don't start one.
+func (c *client) injectTraceContext(request *http.Request) (*http.Request, error) {
+	parent, hasParent := SpanContextFromContext(request.Context())
+	if !hasParent && c.builder.tracer == nil {
+		return request, nil
+	}
+
+	spanContext := SpanContext{
+		TraceID:    parent.TraceID,
+		Sampled:    parent.Sampled,
+		TraceState: parent.TraceState,
+	}
+	if !hasParent {
+		if _, err := rand.Read(spanContext.TraceID[:]); err != nil {
+			return nil, fmt.Errorf("unable to create trace id. %v", err)
+		}
+		spanContext.Sampled = true
+	}
+	if _, err := rand.Read(spanContext.SpanID[:]); err != nil {
+		return nil, fmt.Errorf("unable to create span id. %v", err)
+	}
+
+	request.Header.Set("traceparent", spanContext.Traceparent())
+	if spanContext.TraceState != "" {
+		request.Header.Set("tracestate", spanContext.TraceState)
+	} else {
+		request.Header.Del("tracestate")
+	}
+
+	span := &Span{
+		Name:        "HTTP " + request.Method,
+		SpanContext: spanContext,
+		Parent:      parent,
+		Attributes: map[string]interface{}{
+			AttributeHTTPMethod:  request.Method,
+			AttributeHTTPURL:     c.builder.redactionPolicy.RedactURL(request.URL),
+			AttributeHTTPAttempt: attemptFromContext(request.Context()),
+		},
+	}
+	return request.WithContext(context.WithValue(request.Context(), clientSpanKey{}, span)), nil
+}
+
+// startSpan reports the start of the span created by injectTraceContext, if any.
+func (c *client) startSpan(request *http.Request) *Span {
+	span, ok := request.Context().Value(clientSpanKey{}).(*Span)
+	if !ok {
+		return nil
+	}
+	span.StartTime = time.Now()
+	c.tracer().SpanStarted(span)
+	return span
+}
+
+func (c *client) endSpan(span *Span, response *http.Response, err error) {
+	if span == nil {
+		return
+	}
+	span.EndTime = time.Now()
+	if response != nil {
+		span.Attributes[AttributeHTTPStatusCode] = response.StatusCode
+	}
+	span.Err = err
+	c.tracer().SpanEnded(span)
+}
+
+func (c *client) tracer() Tracer {
+	if c.builder.tracer == nil {
+		return NoopTracer{}
+	}
+	return c.builder.tracer
+}
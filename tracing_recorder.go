@@ -0,0 +1,58 @@
+package gohttpclient
+
+import "sync"
+
+// SpanRecorder is a Tracer keeping every span in memory, meant to be used in tests.
+type SpanRecorder struct {
+	mutex   sync.Mutex
+	started []Span
+	ended   []Span
+}
+
+func (r *SpanRecorder) SpanStarted(span *Span) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.started = append(r.started, copySpan(span))
+}
+
+func (r *SpanRecorder) SpanEnded(span *Span) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.ended = append(r.ended, copySpan(span))
+}
+
+// Started returns the spans that were started, in order.
+func (r *SpanRecorder) Started() []Span {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]Span(nil), r.started...)
+}
+
+// Ended returns the spans that were ended, in order.
+func (r *SpanRecorder) Ended() []Span {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]Span(nil), r.ended...)
+}
+
+// Reset discards every recorded span.
+func (r *SpanRecorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.started = nil
+	r.ended = nil
+}
+
+func copySpan(span *Span) Span {
+	spanCopy := *span
+	spanCopy.Attributes = make(map[string]interface{}, len(span.Attributes))
+	for key, value := range span.Attributes {
+		spanCopy.Attributes[key] = value
+	}
+	return spanCopy
+}
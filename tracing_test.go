@@ -0,0 +1,171 @@
+package gohttpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+
+	// Execution
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Cannot parse traceparent. %v", err)
+	}
+	if !sc.Sampled {
+		t.Error("Span should be sampled")
+	}
+	if sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Invalid traceparent %s", sc.Traceparent())
+	}
+
+	invalidValues := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	}
+	for _, value := range invalidValues {
+		if _, err := ParseTraceparent(value); err == nil {
+			t.Errorf("An error was expected parsing %q", value)
+		}
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+
+	// Initialization
+	var traceparent, tracestate string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		tracestate = r.Header.Get("tracestate")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	recorder := &SpanRecorder{}
+	c := NewBuilder().SetTracer(recorder).Build()
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parent.TraceState = "vendor=value"
+	ctx := ContextWithSpanContext(context.Background(), parent)
+
+	// Execution
+	resp, err := c.Do(ctx, http.MethodPost, server.URL+"/items", nil, map[string]string{"a": "b"})
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing POST: %v", err)
+	}
+	resp.Body.Close()
+
+	sent, err := ParseTraceparent(traceparent)
+	if err != nil {
+		t.Fatalf("Invalid traceparent header %q. %v", traceparent, err)
+	}
+	if sent.TraceID != parent.TraceID {
+		t.Error("Trace id was not propagated")
+	}
+	if sent.SpanID == parent.SpanID {
+		t.Error("A child span id was expected")
+	}
+	if tracestate != "vendor=value" {
+		t.Errorf("Invalid tracestate header %q", tracestate)
+	}
+
+	if len(recorder.Started()) != 1 {
+		t.Fatalf("One started span was expected, got %d", len(recorder.Started()))
+	}
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("One ended span was expected, got %d", len(ended))
+	}
+	span := ended[0]
+	if span.SpanContext.SpanID != sent.SpanID || span.Parent.SpanID != parent.SpanID {
+		t.Error("Span ids don't match the propagated ones")
+	}
+	if span.Attributes[AttributeHTTPMethod] != http.MethodPost ||
+		span.Attributes[AttributeHTTPURL] != server.URL+"/items" ||
+		span.Attributes[AttributeHTTPStatusCode] != http.StatusCreated ||
+		span.Attributes[AttributeHTTPAttempt] != 1 {
+		t.Errorf("Invalid span attributes %v", span.Attributes)
+	}
+	if span.EndTime.Before(span.StartTime) {
+		t.Error("Invalid span times")
+	}
+}
+
+func TestTracingRedactsURL(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	recorder := &SpanRecorder{}
+	c := NewBuilder().
+		SetTracer(recorder).
+		SetRedactionPolicy(RedactionPolicy{QueryParams: []string{"token"}}).
+		Build()
+	url := strings.Replace(server.URL, "http://", "http://user:password@", 1) + "/items?token=secret&page=2"
+
+	// Execution
+	resp, err := c.GET(url, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("One ended span was expected, got %d", len(ended))
+	}
+	spanURL, _ := ended[0].Attributes[AttributeHTTPURL].(string)
+	if strings.Contains(spanURL, "secret") || strings.Contains(spanURL, "password") || !strings.Contains(spanURL, "page=2") {
+		t.Errorf("URL should be redacted, got %q", spanURL)
+	}
+}
+
+func TestTracingWithoutParentOrTracer(t *testing.T) {
+
+	// Initialization
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	// Execution
+	resp, err := NewBuilder().Build().GET(server.URL, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	if traceparent != "" {
+		t.Errorf("No trace should be started, got traceparent %q", traceparent)
+	}
+
+	// Execution
+	recorder := &SpanRecorder{}
+	resp, err = NewBuilder().SetTracer(recorder).Build().GET(server.URL, nil)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	resp.Body.Close()
+	if len(recorder.Ended()) != 1 || recorder.Ended()[0].Parent.IsValid() {
+		t.Fatal("A root span was expected")
+	}
+	if traceparent != recorder.Ended()[0].SpanContext.Traceparent() {
+		t.Errorf("Invalid traceparent header %q", traceparent)
+	}
+}
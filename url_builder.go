@@ -0,0 +1,310 @@
+package gohttpclient
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URLBuilder builds the URL of a request from a path template, like
+// "/users/{id}/orders/{orderID}", escaping the values of its parameters,
+// and from query parameters.
+//
+//	builder := NewURL("/users/{id}/orders").Param("id", userID).Query(filters)
+//	url, err := builder.Build()
+//	response, err := client.Do(builder.Context(ctx), http.MethodGet, url, nil, nil)
+//
+// The context returned by Context carries the template, used instead of the actual
+// path to label metrics and logs.
+type URLBuilder struct {
+	template string
+	params   map[string]string
+	query    url.Values
+	err      error
+}
+
+// NewURL returns a URLBuilder for the template. Parameters are names in braces,
+// like {id}, each one taking a whole path segment or part of it.
+func NewURL(template string) *URLBuilder {
+	return &URLBuilder{
+		template: template,
+		params:   make(map[string]string),
+		query:    make(url.Values),
+	}
+}
+
+// Param sets the value of a template parameter. It's formatted like query values.
+func (u *URLBuilder) Param(name string, value interface{}) *URLBuilder {
+	formatted, ok, err := formatQueryValue(reflect.ValueOf(value))
+	if err != nil {
+		u.setError(fmt.Errorf("unable to format path parameter %s. %v", name, err))
+		return u
+	}
+	if !ok {
+		u.setError(fmt.Errorf("path parameter %s has no value", name))
+		return u
+	}
+	u.params[name] = formatted
+	return u
+}
+
+// Query adds query parameters from values, which can be url.Values, a map with string
+// keys, or a struct as described in EncodeQuery.
+func (u *URLBuilder) Query(values interface{}) *URLBuilder {
+	encoded, err := EncodeQuery(values)
+	if err != nil {
+		u.setError(err)
+		return u
+	}
+	for key, list := range encoded {
+		u.query[key] = append(u.query[key], list...)
+	}
+	return u
+}
+
+// Add adds a single query parameter.
+func (u *URLBuilder) Add(key string, value string) *URLBuilder {
+	u.query.Add(key, value)
+	return u
+}
+
+// Build returns the URL, with the template parameters replaced by their escaped
+// values and the query parameters encoded. Query parameters in the template are kept,
+// and their parameters escaped as query values.
+func (u *URLBuilder) Build() (string, error) {
+	if u.err != nil {
+		return "", u.err
+	}
+
+	var builder strings.Builder
+	rest := u.template
+	inQuery := false
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			builder.WriteString(rest)
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed parameter in template %s", u.template)
+		}
+		end += start
+
+		name := rest[start+1 : end]
+		value, ok := u.params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path parameter %s in template %s", name, u.template)
+		}
+		builder.WriteString(rest[:start])
+		// Parameters in the query, like "/search?q={term}", are escaped as query values
+		inQuery = inQuery || strings.Contains(rest[:start], "?")
+		if inQuery {
+			builder.WriteString(url.QueryEscape(value))
+		} else {
+			builder.WriteString(url.PathEscape(value))
+		}
+		rest = rest[end+1:]
+	}
+
+	built := builder.String()
+	if len(u.query) > 0 {
+		separator := "?"
+		if strings.Contains(built, "?") {
+			separator = "&"
+		}
+		built += separator + u.query.Encode()
+	}
+	return built, nil
+}
+
+// Template returns the unexpanded template.
+func (u *URLBuilder) Template() string {
+	return u.template
+}
+
+// Context returns a copy of ctx carrying the template, like WithRouteTemplate.
+func (u *URLBuilder) Context(ctx context.Context) context.Context {
+	return WithRouteTemplate(ctx, u.template)
+}
+
+func (u *URLBuilder) setError(err error) {
+	if u.err == nil {
+		u.err = err
+	}
+}
+
+// EncodeQuery returns the query parameters in values, which can be url.Values,
+// a map with string keys, or a struct or pointer to one.
+//
+// Struct fields are encoded with the name in their `query` tag, or the field name
+// if none, and omitted with the tag "-". The "omitempty" option omits zero values.
+// Embedded structs are flattened.
+//
+//	type Filters struct {
+//		Status []string  `query:"status"`
+//		Since  time.Time `query:"since,omitempty"`
+//		Limit  int       `query:"limit,omitempty"`
+//	}
+//
+// Slices and arrays make repeated parameters, nil pointers and nil interfaces are
+// omitted, and time.Time values are formatted as RFC 3339.
+func EncodeQuery(values interface{}) (url.Values, error) {
+	encoded := make(url.Values)
+	if values == nil {
+		return encoded, nil
+	}
+	if urlValues, ok := values.(url.Values); ok {
+		for key, list := range urlValues {
+			encoded[key] = append([]string(nil), list...)
+		}
+		return encoded, nil
+	}
+
+	value := reflect.ValueOf(values)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return encoded, nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unable to encode query. Map keys must be strings, not %s", value.Type().Key())
+		}
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			if err := addQueryValue(encoded, key.String(), value.MapIndex(key)); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Struct:
+		if err := encodeQueryStruct(encoded, value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unable to encode query. Unsupported type %s", value.Type())
+	}
+	return encoded, nil
+}
+
+func encodeQueryStruct(encoded url.Values, value reflect.Value) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		fieldValue := value.Field(i)
+
+		name, omitEmpty := field.Name, false
+		if tag, ok := field.Tag.Lookup("query"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, option := range parts[1:] {
+				if option == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		if field.Anonymous && fieldValue.Kind() == reflect.Struct && field.Tag.Get("query") == "" {
+			if err := encodeQueryStruct(encoded, fieldValue); err != nil {
+				return err
+			}
+			continue
+		}
+		// Unexported fields
+		if field.PkgPath != "" {
+			continue
+		}
+		if omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+		if err := addQueryValue(encoded, name, fieldValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addQueryValue adds value under key, once per element if it's a slice or array.
+func addQueryValue(encoded url.Values, key string, value reflect.Value) error {
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < value.Len(); i++ {
+			if err := addQueryValue(encoded, key, value.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	formatted, ok, err := formatQueryValue(value)
+	if err != nil {
+		return fmt.Errorf("unable to encode query parameter %s. %v", key, err)
+	}
+	if ok {
+		encoded.Add(key, formatted)
+	}
+	return nil
+}
+
+// formatQueryValue formats a single value, returning false if it's nil.
+func formatQueryValue(value reflect.Value) (string, bool, error) {
+	if !value.IsValid() {
+		return "", false, nil
+	}
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return "", false, nil
+		}
+		value = value.Elem()
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		return v.Format(time.RFC3339), true, nil
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		return string(text), err == nil, err
+	case fmt.Stringer:
+		return v.String(), true, nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), true, nil
+	case reflect.Float32:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32), true, nil
+	case reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64), true, nil
+	case reflect.Slice:
+		// []byte
+		return string(value.Bytes()), true, nil
+	}
+	return "", false, fmt.Errorf("unsupported type %s", value.Type())
+}
@@ -0,0 +1,154 @@
+package gohttpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type pagination struct {
+	Page  int `query:"page,omitempty"`
+	Limit int `query:"limit"`
+}
+
+type orderFilters struct {
+	pagination
+	Status   []string  `query:"status"`
+	Since    time.Time `query:"since,omitempty"`
+	Customer *string   `query:"customer"`
+	Internal string    `query:"-"`
+	Sort     string
+}
+
+func TestURLBuilder(t *testing.T) {
+
+	// Initialization
+	since := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	builder := NewURL("/users/{id}/orders/{orderID}").
+		Param("id", "john/doe smith").
+		Param("orderID", 42).
+		Query(orderFilters{
+			pagination: pagination{Limit: 10},
+			Status:     []string{"open", "paid"},
+			Since:      since,
+			Internal:   "secret",
+			Sort:       "-date",
+		}).
+		Query(map[string]interface{}{"tag": []int{1, 2}}).
+		Add("q", "a&b")
+
+	// Execution
+	built, err := builder.Build()
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error building URL: %v", err)
+	}
+	expected := "/users/john%2Fdoe%20smith/orders/42?Sort=-date&limit=10&q=a%26b&since=2021-03-04T05%3A06%3A07Z&status=open&status=paid&tag=1&tag=2"
+	if built != expected {
+		t.Errorf("Invalid URL\n%s\n%s", built, expected)
+	}
+	if builder.Template() != "/users/{id}/orders/{orderID}" {
+		t.Errorf("Invalid template %s", builder.Template())
+	}
+}
+
+func TestURLBuilderQueryTemplate(t *testing.T) {
+
+	// Initialization
+	builder := NewURL("/search/{kind}?q={term}&page=1").
+		Param("kind", "a b").
+		Param("term", "fish & chips/+")
+
+	// Execution
+	built, err := builder.Build()
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error building URL: %v", err)
+	}
+	if expected := "/search/a%20b?q=fish+%26+chips%2F%2B&page=1"; built != expected {
+		t.Errorf("Invalid URL\n%s\n%s", built, expected)
+	}
+}
+
+func TestURLBuilderErrors(t *testing.T) {
+
+	// Execution
+	_, missingErr := NewURL("/users/{id}").Build()
+	_, unclosedErr := NewURL("/users/{id").Param("id", 1).Build()
+	_, unsupportedErr := NewURL("/users").Query(42).Build()
+	_, nilParamErr := NewURL("/users/{id}").Param("id", nil).Build()
+
+	// Validation
+	for name, err := range map[string]error{"missing": missingErr, "unclosed": unclosedErr, "unsupported": unsupportedErr, "nil": nilParamErr} {
+		if err == nil {
+			t.Errorf("Error expected for %s parameter", name)
+		}
+	}
+}
+
+func TestEncodeQueryValues(t *testing.T) {
+
+	// Initialization
+	values := url.Values{"a": []string{"1", "2"}}
+
+	// Execution
+	encoded, err := EncodeQuery(values)
+	encoded.Add("a", "3")
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Error encoding query: %v", err)
+	}
+	if len(values["a"]) != 2 || encoded.Encode() != "a=1&a=2&a=3" {
+		t.Errorf("Invalid encoded values %s, original %s", encoded.Encode(), values.Encode())
+	}
+}
+
+func TestBaseURLWithTemplate(t *testing.T) {
+
+	// Initialization
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	defer server.Close()
+
+	recorder := &routeRecorder{}
+	c := NewBuilder().SetBaseURL(server.URL + "/v1").SetMetricsRecorder(recorder).Build()
+	builder := NewURL("/users/{id}").Param("id", 7).Add("expand", "orders")
+
+	// Execution
+	built, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Error building URL: %v", err)
+	}
+	resp, err := c.Do(builder.Context(context.Background()), http.MethodGet, built, nil, nil)
+	if err != nil {
+		t.Fatalf("Error executing GET: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// Validation
+	if string(body) != "/v1/users/7?expand=orders" {
+		t.Errorf("Invalid request URI %s", body)
+	}
+	if recorder.route != "/users/{id}" {
+		t.Errorf("Template was not recorded, got %q", recorder.route)
+	}
+}
+
+type routeRecorder struct {
+	route string
+}
+
+func (r *routeRecorder) RequestStarted(labels MetricLabels) {}
+
+func (r *routeRecorder) RequestFinished(labels MetricLabels, duration time.Duration, err error) {
+	r.route = labels.Route
+}
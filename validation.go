@@ -0,0 +1,171 @@
+package gohttpclient
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// totalTimeout covers the entire exchange, from Dial (if a connection is not reused)
+// to reading the body.
+func (b *clientBuilder) totalTimeout() time.Duration {
+	return b.expectContinueTimeout + b.tlsHandshakeTimeout + b.connectionTimeout + b.responseTimeOut
+}
+
+func (b *clientBuilder) BuildE() (ContextClient, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b.Build(), nil
+}
+
+func (b *clientBuilder) Validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Timeouts
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"connection timeout", b.connectionTimeout},
+		{"response timeout", b.responseTimeOut},
+		{"expect continue timeout", b.expectContinueTimeout},
+		{"TLS handshake timeout", b.tlsHandshakeTimeout},
+		{"idle connection timeout", b.idleConnTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			addProblem("%s must not be negative, got %v", timeout.name, timeout.value)
+		}
+	}
+
+	// Connection pool
+	sizes := []struct {
+		name  string
+		value int
+	}{
+		{"max idle connections", b.maxIdleConns},
+		{"max idle connections per host", b.maxIdleConnsPerHost},
+		{"max connections per host", b.maxConnsPerHost},
+	}
+	for _, size := range sizes {
+		if size.value < 0 {
+			addProblem("%s must not be negative, got %d", size.name, size.value)
+		}
+	}
+	if b.maxIdleConns > 0 && b.maxIdleConnsPerHost > b.maxIdleConns {
+		addProblem("max idle connections per host %d is larger than max idle connections %d", b.maxIdleConnsPerHost, b.maxIdleConns)
+	}
+	if b.maxConnsPerHost > 0 && b.maxIdleConnsPerHost > b.maxConnsPerHost {
+		addProblem("max idle connections per host %d is larger than max connections per host %d", b.maxIdleConnsPerHost, b.maxConnsPerHost)
+	}
+
+	// Dialer
+	if b.localAddr != nil {
+		if _, ok := b.localAddr.(*net.TCPAddr); !ok {
+			addProblem("dialer local address must be a *net.TCPAddr, got %T", b.localAddr)
+		}
+	}
+	for _, host := range sortedKeys(b.hostOverrides) {
+		address := b.hostOverrides[host]
+		if host == "" || address == "" {
+			addProblem("host override %q to %q must have a host and an address", host, address)
+		} else if net.ParseIP(address) == nil && strings.Contains(address, ":") {
+			addProblem("host override address %q must not have a port", address)
+		}
+	}
+	if b.dnsCacheTTL < 0 || b.negativeCacheTTL < 0 {
+		addProblem("DNS cache TTLs must not be negative, got %v and %v", b.dnsCacheTTL, b.negativeCacheTTL)
+	}
+	if b.proxyURL != nil {
+		if b.proxyURL.Host == "" {
+			addProblem("proxy URL %q must be absolute", b.proxyURL)
+		} else if scheme := b.proxyURL.Scheme; scheme != "http" && scheme != "https" && scheme != "socks5" {
+			addProblem("proxy URL scheme must be http, https or socks5, got %q", scheme)
+		}
+	}
+
+	// Requests
+	for _, name := range sortedKeys(b.headers) {
+		if !isHeaderName(name) {
+			addProblem("invalid header name %q", name)
+		}
+	}
+	if b.digestAuthEnabled && b.digestUsername == "" {
+		addProblem("digest auth username must not be empty")
+	}
+	if b.logBodyLimit < 0 {
+		addProblem("log body limit must not be negative, got %d", b.logBodyLimit)
+	}
+	for _, limit := range []BandwidthLimit{b.bandwidthLimit, b.connectionBandwidthLimit} {
+		if limit.Upload < 0 || limit.Download < 0 || limit.Burst < 0 {
+			addProblem("bandwidth limits must not be negative, got %+v", limit)
+		}
+	}
+	if b.requestRate < 0 || b.requestBurst < 0 {
+		addProblem("request rate limit must not be negative, got %v per second and a burst of %d", b.requestRate, b.requestBurst)
+	}
+
+	// Hedging
+	policy := b.hedgePolicy
+	if policy.Delay < 0 {
+		addProblem("hedge delay must not be negative, got %v", policy.Delay)
+	}
+	if policy.Percentile < 0 || policy.Percentile > 1 {
+		addProblem("hedge percentile must be between 0 and 1, got %v", policy.Percentile)
+	}
+	if policy.MaxHedges < 0 {
+		addProblem("max hedges must not be negative, got %d", policy.MaxHedges)
+	}
+	if policy.Budget < 0 || policy.Budget > 1 {
+		addProblem("hedge budget must be between 0 and 1, got %v", policy.Budget)
+	}
+
+	// Retries
+	retries := b.retryPolicy
+	if retries.MaxRetries < 0 || retries.Backoff < 0 || retries.MaxBackoff < 0 {
+		addProblem("retry policy must not be negative, got %d retries, %v backoff and %v max backoff", retries.MaxRetries, retries.Backoff, retries.MaxBackoff)
+	}
+
+	// Endpoints
+	if len(b.endpoints) > 0 && b.endpointSource != nil {
+		addProblem("endpoints and an endpoint source must not be set together")
+	}
+	if b.baseURL != "" && (len(b.endpoints) > 0 || b.endpointSource != nil) {
+		addProblem("base URL %q and endpoints must not be set together", b.baseURL)
+	}
+	for _, endpoint := range b.endpoints {
+		if parsed, err := url.Parse(endpoint); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			addProblem("endpoint base URL must be absolute, got %q", endpoint)
+		}
+	}
+	if b.baseURL != "" {
+		if parsed, err := url.Parse(b.baseURL); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			addProblem("base URL must be absolute, got %q", b.baseURL)
+		}
+	}
+	if b.ejectionFailures < 0 || b.ejectionTime < 0 {
+		addProblem("outlier ejection settings must not be negative, got %d failures and %v", b.ejectionFailures, b.ejectionTime)
+	}
+	if check := b.healthCheck; check != nil {
+		if len(b.endpoints) == 0 && b.endpointSource == nil && b.baseURL == "" {
+			addProblem("health check needs endpoints to check")
+		}
+		if check.Interval < 0 || check.Timeout < 0 {
+			addProblem("health check interval and timeout must not be negative, got %v and %v", check.Interval, check.Timeout)
+		}
+		if check.HealthyThreshold < 0 || check.UnhealthyThreshold < 0 {
+			addProblem("health check thresholds must not be negative, got %d and %d", check.HealthyThreshold, check.UnhealthyThreshold)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package gohttpclient
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildEDefaults(t *testing.T) {
+
+	// Execution
+	c, err := NewBuilder().BuildE()
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Default configuration should be valid, got %v", err)
+	}
+	if c == nil {
+		t.Error("Client expected")
+	}
+}
+
+func TestBuildEReportsEveryProblem(t *testing.T) {
+
+	// Initialization
+	builder := NewBuilder().
+		SetTLSHandshakeTimeout(-40*time.Second).
+		SetMaxIdleConnections(-1).
+		SetMaxIdleConnectionsPerHost(50).
+		SetMaxConnectionsPerHost(10).
+		SetDialerLocalAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}).
+		SetHostOverrides(map[string]string{"api.example.com": "10.0.0.5:8443"}).
+		SetEndpoints(RoundRobin, "/relative")
+
+	// Execution
+	c, err := builder.BuildE()
+
+	// Validation
+	if c != nil {
+		t.Error("Client should not be built")
+	}
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Config error expected, got %v", err)
+	}
+	expected := []string{
+		"TLS handshake timeout must not be negative",
+		"max idle connections must not be negative",
+		"max idle connections per host 50 is larger than max connections per host 10",
+		"dialer local address must be a *net.TCPAddr, got *net.UDPAddr",
+		`host override address "10.0.0.5:8443" must not have a port`,
+		`endpoint base URL must be absolute, got "/relative"`,
+	}
+	if len(configErr.Problems) != len(expected) {
+		t.Fatalf("Invalid problems %q", configErr.Problems)
+	}
+	for i, problem := range configErr.Problems {
+		if !strings.HasPrefix(problem, expected[i]) {
+			t.Errorf("Problem %q expected, got %q", expected[i], problem)
+		}
+	}
+}
+
+func TestValidateHedgePolicy(t *testing.T) {
+
+	// Execution
+	err := NewBuilder().SetHedgePolicy(HedgePolicy{Delay: -time.Second, Percentile: 95}).Validate()
+
+	// Validation
+	if err == nil || !strings.Contains(err.Error(), "hedge delay") || !strings.Contains(err.Error(), "hedge percentile") {
+		t.Errorf("Invalid hedge policy should be reported, got %v", err)
+	}
+}
+
+func TestValidateBaseURL(t *testing.T) {
+
+	// Initialization
+	builder := NewBuilder().SetEndpoints(LeastOutstanding, "https://a.example.com", "https://b.example.com")
+
+	// Execution
+	builder.SetBaseURL("https://api.example.com")
+	conflictErr := builder.Validate()
+	relativeErr := NewBuilder().SetBaseURL("/v1").Validate()
+
+	// Validation
+	if builder.(*clientBuilder).balancing != LeastOutstanding {
+		t.Error("Base URL should not change the balancing")
+	}
+	if conflictErr == nil || !strings.Contains(conflictErr.Error(), "base URL") {
+		t.Errorf("Base URL and endpoints conflict should be reported, got %v", conflictErr)
+	}
+	if relativeErr == nil || !strings.Contains(relativeErr.Error(), "base URL must be absolute") {
+		t.Errorf("Relative base URL should be reported, got %v", relativeErr)
+	}
+}